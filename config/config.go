@@ -0,0 +1,292 @@
+// Package config reads the config file for the whole bot, with the IRC connection, which
+// plugins to enable and their settings. Env vars override the file, using the same names
+// as before there was a config file, and the bot's flags override both.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/oddlid/dvdgbot/larsmonsen"
+	"github.com/oddlid/dvdgbot/leet"
+	"github.com/oddlid/dvdgbot/morse"
+	"github.com/oddlid/dvdgbot/quoteshuffle"
+	"github.com/oddlid/dvdgbot/timestamp"
+	"github.com/oddlid/dvdgbot/userwatch"
+	"github.com/oddlid/dvdgbot/xkcdbot"
+)
+
+// Env vars read by Load, overriding the file
+const (
+	EnvLeetHour            = `LEETBOT_HOUR`
+	EnvLeetMinute          = `LEETBOT_MINUTE`
+	EnvLeetScoreFile       = `LEETBOT_SCOREFILE`
+	EnvLeetBonusConfigFile = `LEETBOT_BONUSCONFIGFILE`
+	EnvLeetNtpServer       = `LEETBOT_NTP_SERVER`
+	EnvLarsMonsenFactsFile = `LARSMONSENFACTS_FILE`
+	EnvUserWatchConfigFile = `USERWATCH_CFGFILE`
+)
+
+// Config is the config for the whole bot
+type Config struct {
+	Plugins        Plugins       `yaml:"plugins"`
+	IRC            IRC           `yaml:"irc"`
+	HTTPAddr       string        `yaml:"http_addr"`
+	LogLevel       string        `yaml:"log_level"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// IRC is the connection to the IRC server
+type IRC struct {
+	TLS      *bool    `yaml:"tls"` // true if not set
+	Server   string   `yaml:"server"`
+	User     string   `yaml:"user"`
+	Nick     string   `yaml:"nick"`
+	Password string   `yaml:"password"`
+	Channels []string `yaml:"channels"` // "#chan passwd" if a channel needs a password
+	Debug    bool     `yaml:"debug"`
+}
+
+// Plugin has what's common for all plugins
+type Plugin struct {
+	Enabled      *bool    `yaml:"enabled"`       // on if not set, unless the plugin says otherwise
+	OnlyChannels []string `yaml:"only_channels"` // all channels if empty
+}
+
+// Plugins are the settings for each plugin
+type Plugins struct {
+	Leet       LeetPlugin       `yaml:"leet"`
+	LarsMonsen LarsMonsenPlugin `yaml:"larsmonsen"`
+	Morse      Plugin           `yaml:"morse"`
+	Timestamp  Plugin           `yaml:"timestamp"`
+	Xkcd       XkcdPlugin       `yaml:"xkcd"`
+	UserWatch  UserWatchPlugin  `yaml:"userwatch"`
+}
+
+type LeetPlugin struct {
+	Plugin        `yaml:",inline"`
+	leet.Settings `yaml:",inline"`
+}
+
+type LarsMonsenPlugin struct {
+	Plugin              `yaml:",inline"`
+	larsmonsen.Settings `yaml:",inline"`
+}
+
+type XkcdPlugin struct {
+	Plugin           `yaml:",inline"`
+	xkcdbot.Settings `yaml:",inline"`
+}
+
+type UserWatchPlugin struct {
+	Plugin             `yaml:",inline"`
+	userwatch.Settings `yaml:",inline"`
+}
+
+// Entry is a plugin as given in the config, with its name in the plugin registry
+type Entry struct {
+	Settings any // the plugin's own settings type, or nil
+	Name     string
+	Channels []string
+	Enabled  bool
+}
+
+// List returns all plugins, in the order they should be set up
+func (p Plugins) List() []Entry {
+	return []Entry{
+		{Name: leet.PluginName, Enabled: p.Leet.IsEnabled(true), Channels: p.Leet.OnlyChannels, Settings: p.Leet.Settings},
+		{Name: larsmonsen.DefaultCommandName, Enabled: p.LarsMonsen.IsEnabled(true), Channels: p.LarsMonsen.OnlyChannels, Settings: p.LarsMonsen.Settings},
+		{Name: morse.PluginName, Enabled: p.Morse.IsEnabled(true), Channels: p.Morse.OnlyChannels},
+		{Name: timestamp.PluginName, Enabled: p.Timestamp.IsEnabled(true), Channels: p.Timestamp.OnlyChannels},
+		{Name: xkcdbot.DefaultCommandName, Enabled: p.Xkcd.IsEnabled(true), Channels: p.Xkcd.OnlyChannels, Settings: p.Xkcd.Settings},
+		{Name: userwatch.PluginName, Enabled: p.UserWatch.IsEnabled(false), Channels: p.UserWatch.OnlyChannels, Settings: p.UserWatch.Settings},
+	}
+}
+
+// IsEnabled returns Enabled, or def if not set
+func (p Plugin) IsEnabled(def bool) bool {
+	if p.Enabled == nil {
+		return def
+	}
+	return *p.Enabled
+}
+
+// UseTLS returns TLS, or true if not set
+func (irc IRC) UseTLS() bool {
+	return irc.TLS == nil || *irc.TLS
+}
+
+// Load reads the config from filename, and overrides it with the env vars. An empty filename
+// gives a config with only what's set by env vars.
+func Load(filename string) (*Config, error) {
+	cfg := &Config{}
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := parse(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.setDefaults()
+	return cfg, nil
+}
+
+func parse(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	// typos should not be silently ignored, but an empty file is fine
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func envInt(key string, dst **int) error {
+	val, found := os.LookupEnv(key)
+	if !found {
+		return nil
+	}
+	num, err := strconv.Atoi(val)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = &num
+	return nil
+}
+
+func envStr(key string, dst *string) {
+	if val, found := os.LookupEnv(key); found {
+		*dst = val
+	}
+}
+
+func (cfg *Config) applyEnv() error {
+	ls := &cfg.Plugins.Leet.Settings
+	if err := envInt(EnvLeetHour, &ls.Hour); err != nil {
+		return err
+	}
+	if err := envInt(EnvLeetMinute, &ls.Minute); err != nil {
+		return err
+	}
+	envStr(EnvLeetScoreFile, &ls.ScoreFile)
+	envStr(EnvLeetBonusConfigFile, &ls.BonusConfigFile)
+	envStr(EnvLeetNtpServer, &ls.NtpServer)
+	envStr(EnvLarsMonsenFactsFile, &cfg.Plugins.LarsMonsen.FactsFile)
+	envStr(EnvUserWatchConfigFile, &cfg.Plugins.UserWatch.ConfigFile)
+	return nil
+}
+
+// setDefaults fills in the defaults for plugin settings not given in the file or env
+func (cfg *Config) setDefaults() {
+	if cfg.Plugins.LarsMonsen.FactsFile == "" {
+		cfg.Plugins.LarsMonsen.FactsFile = larsmonsen.DefaultFactsFile
+	}
+	if cfg.Plugins.LarsMonsen.Pattern == "" {
+		cfg.Plugins.LarsMonsen.Pattern = larsmonsen.DefaultPattern
+	}
+	if cfg.Plugins.Xkcd.Timeout == 0 {
+		cfg.Plugins.Xkcd.Timeout = xkcdbot.DefaultTimeout
+	}
+	// userwatch limits are off when less than zero
+	uw := &cfg.Plugins.UserWatch
+	if uw.Cooldown == 0 {
+		uw.Cooldown = userwatch.DefaultCooldown
+	}
+	if uw.ChannelLimit == 0 {
+		uw.ChannelLimit = userwatch.DefaultChannelLimit
+	}
+	if uw.ChannelPeriod == 0 {
+		uw.ChannelPeriod = userwatch.DefaultChannelPeriod
+	}
+	if uw.SplitTimeout == 0 {
+		uw.SplitTimeout = userwatch.DefaultSplitTimeout
+	}
+}
+
+// channelName returns the name of a channel given as "#chan passwd"
+func channelName(channel string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(channel), " ")
+	return name
+}
+
+func validChannel(name string) bool {
+	return strings.HasPrefix(name, "#") || strings.HasPrefix(name, "&")
+}
+
+// Validate checks the whole config, including that the files for enabled plugins can be read,
+// and returns all problems found
+func (cfg *Config) Validate() error {
+	var errs []error
+	add := func(section string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", section, err))
+		}
+	}
+
+	if cfg.IRC.Server == "" {
+		add("irc", errors.New("no server"))
+	}
+	if cfg.IRC.Nick == "" {
+		add("irc", errors.New("no nick"))
+	}
+	for _, channel := range cfg.IRC.Channels {
+		if name := channelName(channel); !validChannel(name) {
+			add("irc", fmt.Errorf("invalid channel name: %q", name))
+		}
+	}
+	if cfg.HTTPAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.HTTPAddr); err != nil {
+			add("http_addr", err)
+		}
+	}
+	if cfg.LogLevel != "" {
+		if _, err := zerolog.ParseLevel(cfg.LogLevel); err != nil {
+			add("log_level", err)
+		}
+	}
+	if cfg.ReloadInterval < 0 {
+		add("reload_interval", errors.New("can not be negative"))
+	}
+
+	p := cfg.Plugins
+	for _, e := range p.List() {
+		for _, channel := range e.Channels {
+			if !validChannel(channel) {
+				add("plugins."+e.Name, fmt.Errorf("invalid channel name in only_channels: %q", channel))
+			}
+		}
+	}
+	if p.Leet.IsEnabled(true) {
+		add("plugins.leet", p.Leet.Validate())
+	}
+	if p.LarsMonsen.IsEnabled(true) {
+		_, err := quoteshuffle.New(p.LarsMonsen.FactsFile)
+		add("plugins.larsmonsen", err)
+		_, err = regexp.Compile(p.LarsMonsen.Pattern)
+		add("plugins.larsmonsen", err)
+	}
+	if p.Xkcd.IsEnabled(true) && p.Xkcd.Timeout < 0 {
+		add("plugins.xkcd", errors.New("timeout can not be negative"))
+	}
+	if p.UserWatch.IsEnabled(false) && p.UserWatch.ConfigFile == "" {
+		add("plugins.userwatch", errors.New("no config_file"))
+	}
+
+	return errors.Join(errs...)
+}
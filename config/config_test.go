@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oddlid/dvdgbot/leet"
+	"github.com/oddlid/dvdgbot/userwatch"
+	"github.com/oddlid/dvdgbot/xkcdbot"
+)
+
+const testConfig = `
+irc:
+  server: irc.example.org:6697
+  nick: testbot
+  channels:
+    - "#blackhole"
+http_addr: ":8080"
+plugins:
+  leet:
+    hour: 13
+    minute: 37
+    channels:
+      "#blackhole":
+        render: compact
+        overshoot_tax: 5
+  larsmonsen:
+    enabled: false
+  xkcd:
+    only_channels:
+      - "#blackhole"
+  userwatch:
+    enabled: true
+    config_file: /tmp/userwatch.json
+    cooldown: 2m
+    channel_limit: -1
+`
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "dvdgbot.yaml")
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.IRC.Server != "irc.example.org:6697" || cfg.IRC.Nick != "testbot" {
+		t.Errorf("Unexpected IRC config: %+v", cfg.IRC)
+	}
+	if !cfg.IRC.UseTLS() {
+		t.Errorf("Expected TLS when not set")
+	}
+	lp := cfg.Plugins.Leet
+	if !lp.IsEnabled(true) {
+		t.Errorf("Expected leet enabled when not set")
+	}
+	if lp.Hour == nil || *lp.Hour != 13 || lp.Minute == nil || *lp.Minute != 37 {
+		t.Errorf("Unexpected leet time: %v:%v", lp.Hour, lp.Minute)
+	}
+	cs, found := lp.Channels["#blackhole"]
+	if !found || cs.Render == nil || *cs.Render != "compact" || cs.OvershootTax == nil || *cs.OvershootTax != 5 {
+		t.Errorf("Unexpected channel settings: %+v", cs)
+	}
+	if cfg.Plugins.LarsMonsen.IsEnabled(true) {
+		t.Errorf("Expected larsmonsen disabled")
+	}
+	if !cfg.Plugins.UserWatch.IsEnabled(false) {
+		t.Errorf("Expected userwatch enabled")
+	}
+	uw := cfg.Plugins.UserWatch
+	if uw.Cooldown != 2*time.Minute || uw.ChannelLimit != -1 || uw.SplitTimeout != userwatch.DefaultSplitTimeout {
+		t.Errorf("Unexpected userwatch limits: %+v", uw.Settings)
+	}
+	if cfg.Plugins.Xkcd.Timeout != xkcdbot.DefaultTimeout {
+		t.Errorf("Expected default xkcd timeout, got %v", cfg.Plugins.Xkcd.Timeout)
+	}
+
+	enabled := map[string]bool{}
+	for _, e := range cfg.Plugins.List() {
+		enabled[e.Name] = e.Enabled
+		if e.Name == xkcdbot.DefaultCommandName && (len(e.Channels) != 1 || e.Channels[0] != "#blackhole") {
+			t.Errorf("Expected xkcd limited to #blackhole, got %v", e.Channels)
+		}
+	}
+	want := map[string]bool{"leet": true, "larsmonsen": false, "morse": true, "timestamp": true, "xkcd": true, "userwatch": true}
+	for name, on := range want {
+		if enabled[name] != on {
+			t.Errorf("Expected %s enabled = %v", name, on)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv(EnvLeetHour, "9")
+	t.Setenv(EnvLeetScoreFile, "/tmp/scores.json")
+	cfg, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := cfg.Plugins.Leet
+	if *lp.Hour != 9 || *lp.Minute != 37 {
+		t.Errorf("Expected env to override hour only, got %d:%d", *lp.Hour, *lp.Minute)
+	}
+	if lp.ScoreFile != "/tmp/scores.json" {
+		t.Errorf("Expected score file from env, got %q", lp.ScoreFile)
+	}
+
+	t.Setenv(EnvLeetMinute, "soon")
+	if _, err := Load(""); err == nil {
+		t.Errorf("Expected error for invalid minute in env")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "nope.yaml")); err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+	if _, err := Load(writeConfig(t, "irc:\n  sever: irc.example.org\n")); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+	if _, err := Load(writeConfig(t, "reload_interval: often\n")); err == nil {
+		t.Errorf("Expected error for invalid duration")
+	}
+	cfg, err := Load(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("Expected empty file to be fine, got: %v", err)
+	}
+	if cfg.ReloadInterval != 0 || cfg.IRC.Server != "" {
+		t.Errorf("Expected zero config from empty file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got: %v", err)
+	}
+
+	hour := 24
+	render := "fancy"
+	cfg.Plugins.Leet.Hour = &hour
+	cfg.Plugins.Leet.Channels["#blackhole"] = leet.ChannelSettings{Render: &render}
+	cfg.IRC.Channels = append(cfg.IRC.Channels, "blackhole")
+	cfg.HTTPAddr = "8080"
+	cfg.Plugins.UserWatch.ConfigFile = ""
+	cfg.Plugins.Morse.OnlyChannels = []string{"morsechan"}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected errors")
+	}
+	for _, want := range []string{"hour", "fancy", "blackhole", "http_addr", "userwatch", "morsechan"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error about %q, got: %v", want, err)
+		}
+	}
+}
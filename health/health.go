@@ -0,0 +1,214 @@
+// Package health serves /healthz and /readyz endpoints, reporting the IRC connection state,
+// joined channels, and checks added by other modules.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	ircevent "github.com/thoj/go-ircevent"
+)
+
+var _log = log.With().Str("package", "health").Logger()
+
+// Check returns details about a part of the bot, and an error if it's not healthy
+type Check func() (any, error)
+
+// CheckResult is the outcome of a Check
+type CheckResult struct {
+	Details any    `json:"details,omitempty"`
+	Error   string `json:"error,omitempty"`
+	OK      bool   `json:"ok"`
+}
+
+// IRCStatus is the state of the IRC connection
+type IRCStatus struct {
+	ConnectedSince time.Time `json:"connected_since"`
+	Nick           string    `json:"nick,omitempty"`
+	Configured     []string  `json:"configured_channels"`
+	Joined         []string  `json:"joined_channels"`
+	Missing        []string  `json:"missing_channels"`
+	Connected      bool      `json:"connected"`
+}
+
+// Report is the response from both endpoints
+type Report struct {
+	IRC    IRCStatus              `json:"irc"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+	OK     bool                   `json:"ok"`
+}
+
+// Monitor keeps track of the IRC connection, by callbacks on the connection
+type Monitor struct {
+	ic         *ircevent.Connection
+	since      time.Time
+	joined     map[string]bool
+	checks     map[string]Check
+	configured []string
+	mu         sync.RWMutex
+	welcomed   bool
+}
+
+// channelName returns the lower case name of a channel, without any password,
+// as given to the bot like "#chan passwd"
+func channelName(channel string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(channel), " ")
+	return strings.ToLower(name)
+}
+
+// New returns a Monitor for ic, that is ready when all the given channels are joined
+func New(ic *ircevent.Connection, channels []string) *Monitor {
+	m := &Monitor{
+		ic:     ic,
+		joined: make(map[string]bool),
+		checks: make(map[string]Check),
+	}
+	for _, channel := range channels {
+		if name := channelName(channel); name != "" {
+			m.configured = append(m.configured, name)
+		}
+	}
+	sort.Strings(m.configured)
+
+	ic.AddCallback("001", func(*ircevent.Event) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.welcomed = true
+		m.since = time.Now()
+		m.joined = make(map[string]bool) // a new connection has not joined anything yet
+	})
+	ic.AddCallback("ERROR", func(e *ircevent.Event) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.welcomed = false
+		m.joined = make(map[string]bool)
+		_log.Warn().Str("message", e.Message()).Msg("Server closed the connection")
+	})
+	ic.AddCallback("JOIN", func(e *ircevent.Event) {
+		if e.Nick == ic.GetNick() && len(e.Arguments) > 0 {
+			m.setJoined(e.Arguments[0], true)
+		}
+	})
+	ic.AddCallback("PART", func(e *ircevent.Event) {
+		if e.Nick == ic.GetNick() && len(e.Arguments) > 0 {
+			m.setJoined(e.Arguments[0], false)
+		}
+	})
+	ic.AddCallback("KICK", func(e *ircevent.Event) {
+		if len(e.Arguments) > 1 && e.Arguments[1] == ic.GetNick() {
+			m.setJoined(e.Arguments[0], false)
+		}
+	})
+
+	return m
+}
+
+func (m *Monitor) setJoined(channel string, joined bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if joined {
+		m.joined[channelName(channel)] = true
+	} else {
+		delete(m.joined, channelName(channel))
+	}
+}
+
+// AddCheck adds a check that must pass for the bot to be healthy
+func (m *Monitor) AddCheck(name string, check Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks[name] = check
+}
+
+func (m *Monitor) ircStatus() IRCStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := IRCStatus{
+		Connected:  m.welcomed && m.ic.Connected(),
+		Configured: m.configured,
+		Joined:     make([]string, 0, len(m.joined)),
+		Missing:    make([]string, 0),
+	}
+	if st.Connected {
+		st.ConnectedSince = m.since
+		st.Nick = m.ic.GetNick()
+	}
+	for name := range m.joined {
+		st.Joined = append(st.Joined, name)
+	}
+	sort.Strings(st.Joined)
+	for _, name := range m.configured {
+		if !m.joined[name] {
+			st.Missing = append(st.Missing, name)
+		}
+	}
+	return st
+}
+
+func (m *Monitor) runChecks() (map[string]CheckResult, bool) {
+	m.mu.RLock()
+	checks := make(map[string]Check, len(m.checks))
+	for name, check := range m.checks {
+		checks[name] = check
+	}
+	m.mu.RUnlock()
+
+	ok := true
+	results := make(map[string]CheckResult, len(checks))
+	for name, check := range checks {
+		details, err := check()
+		cr := CheckResult{Details: details, OK: err == nil}
+		if err != nil {
+			cr.Error = err.Error()
+			ok = false
+		}
+		results[name] = cr
+	}
+	return results, ok
+}
+
+// Health reports if the bot is connected, and all checks pass
+func (m *Monitor) Health() Report {
+	r := Report{IRC: m.ircStatus()}
+	var checksOK bool
+	r.Checks, checksOK = m.runChecks()
+	r.OK = r.IRC.Connected && checksOK
+	return r
+}
+
+// Ready reports if the bot is connected, and has joined all configured channels
+func (m *Monitor) Ready() Report {
+	r := Report{IRC: m.ircStatus()}
+	r.OK = r.IRC.Connected && len(r.IRC.Missing) == 0
+	return r
+}
+
+func serveReport(w http.ResponseWriter, r Report) {
+	status := http.StatusOK
+	if !r.OK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		_log.Error().Err(err).Str("func", "serveReport").Send()
+	}
+}
+
+// RegisterHandlers adds /healthz and /readyz to mux. Both respond with 200 if OK,
+// and 503 if not, with a Report in the body.
+func (m *Monitor) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		serveReport(w, m.Health())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		serveReport(w, m.Ready())
+	})
+}
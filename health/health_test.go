@@ -0,0 +1,96 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ircevent "github.com/thoj/go-ircevent"
+)
+
+const testNick = "leetbot"
+
+func newTestMonitor(t *testing.T) (*Monitor, *ircevent.Connection) {
+	t.Helper()
+	ic := ircevent.IRC(testNick, testNick)
+	return New(ic, []string{"#Blackhole", "#secret passwd"}), ic
+}
+
+func event(ic *ircevent.Connection, code, nick string, args ...string) {
+	ic.RunCallbacks(&ircevent.Event{Code: code, Nick: nick, Arguments: args})
+}
+
+func get(t *testing.T, m *Monitor, path string) (int, Report) {
+	t.Helper()
+	mux := http.NewServeMux()
+	m.RegisterHandlers(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var r Report
+	if err := json.NewDecoder(rec.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, r
+}
+
+func TestReady(t *testing.T) {
+	m, ic := newTestMonitor(t)
+
+	if code, r := get(t, m, "/readyz"); code != http.StatusServiceUnavailable || r.IRC.Connected {
+		t.Errorf("Expected not ready before connecting, got %d: %+v", code, r)
+	}
+
+	event(ic, "001", "server", testNick, "Welcome")
+	event(ic, "JOIN", testNick, "#blackhole")
+	event(ic, "JOIN", "someone", "#secret") // not us
+	code, r := get(t, m, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready with a channel missing, got %d", code)
+	}
+	if len(r.IRC.Missing) != 1 || r.IRC.Missing[0] != "#secret" {
+		t.Errorf("Expected #secret missing, got %v", r.IRC.Missing)
+	}
+
+	event(ic, "JOIN", testNick, "#Secret")
+	if code, r := get(t, m, "/readyz"); code != http.StatusOK || len(r.IRC.Joined) != 2 {
+		t.Errorf("Expected ready with 2 channels joined, got %d: %+v", code, r)
+	}
+
+	event(ic, "KICK", "op", "#secret", testNick, "bye")
+	if code, _ := get(t, m, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready after kick, got %d", code)
+	}
+
+	event(ic, "JOIN", testNick, "#secret")
+	event(ic, "ERROR", "", "Closing link")
+	if code, r := get(t, m, "/readyz"); code != http.StatusServiceUnavailable || r.IRC.Connected {
+		t.Errorf("Expected not ready after error from server, got %d: %+v", code, r)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	m, ic := newTestMonitor(t)
+	event(ic, "001", "server", testNick, "Welcome")
+
+	var fail error
+	m.AddCheck("leet", func() (any, error) {
+		return map[string]int{"cron_entries": 3}, fail
+	})
+
+	code, r := get(t, m, "/healthz")
+	if code != http.StatusOK || !r.Checks["leet"].OK {
+		t.Errorf("Expected healthy, got %d: %+v", code, r)
+	}
+	// joined channels don't matter for health
+	if len(r.IRC.Missing) != 2 {
+		t.Errorf("Expected 2 missing channels, got %v", r.IRC.Missing)
+	}
+
+	fail = errors.New("latest save failed")
+	code, r = get(t, m, "/healthz")
+	if code != http.StatusServiceUnavailable || r.Checks["leet"].Error != fail.Error() {
+		t.Errorf("Expected unhealthy with error, got %d: %+v", code, r)
+	}
+}
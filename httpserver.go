@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const httpShutdownTimeout = 5 * time.Second
+
+// serveHTTP starts serving mux on addr in the background, until ctx is done
+func serveHTTP(ctx context.Context, addr string, mux *http.ServeMux) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", addr).Msg("Starting HTTP server")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Str("addr", addr).Msg("HTTP server failed")
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(sctx); err != nil {
+			log.Error().Err(err).Msg("Error shutting down HTTP server")
+		}
+	}()
+}
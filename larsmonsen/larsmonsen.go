@@ -5,7 +5,9 @@ import (
 
 	"github.com/go-chat-bot/bot"
 
+	"github.com/oddlid/dvdgbot/plugin"
 	"github.com/oddlid/dvdgbot/quoteshuffle"
+	"github.com/oddlid/dvdgbot/reload"
 )
 
 type LarsMonsen struct {
@@ -25,6 +27,7 @@ func New(fileName, pattern string) (*LarsMonsen, error) {
 	if err != nil {
 		return nil, err
 	}
+	qd.OnSave = reload.Saved // so our own saves are not reloaded
 
 	rx, err := regexp.Compile(pattern)
 	if err != nil {
@@ -43,10 +46,34 @@ func (lm *LarsMonsen) Quote(command *bot.PassiveCmd) (string, error) {
 	return "", nil
 }
 
-// func init() {
-// 	if lm, err := New(util.EnvDefStr("LARSMONSENFACTS_FILE", DefaultFactsFile), DefaultPattern); err != nil {
-// 		log.Error().Err(err).Send()
-// 	} else {
-// 		bot.RegisterPassiveCommand(DefaultCommandName, lm.Quote)
-// 	}
-// }
+// Reload reads the facts from file again, keeping the current ones if it fails
+func (lm *LarsMonsen) Reload() error {
+	return lm.qd.Reload()
+}
+
+// Settings for the plugin, as given in the bot's config file
+type Settings struct {
+	FactsFile string `yaml:"facts_file"`
+	Pattern   string `yaml:"pattern"`
+}
+
+func init() {
+	plugin.Register(DefaultCommandName, setupPlugin)
+}
+
+func setupPlugin(p *plugin.Plugin, h plugin.Host) error {
+	s, _ := h.Settings.(Settings)
+	if s.FactsFile == "" {
+		s.FactsFile = DefaultFactsFile
+	}
+	if s.Pattern == "" {
+		s.Pattern = DefaultPattern
+	}
+	lm, err := New(s.FactsFile, s.Pattern)
+	if err != nil {
+		return err
+	}
+	reload.Register(DefaultCommandName, lm.Reload, s.FactsFile)
+	p.RegisterPassiveCommand(DefaultCommandName, lm.Quote)
+	return nil
+}
@@ -0,0 +1,291 @@
+package leet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	dateFmt   = "2006-01-02"
+	yearlyFmt = "01-02"
+	icalFmt   = "20060102"
+)
+
+// CalendarRule is a day, or a set of days, that is special for a channel.
+// Exactly one of Date, Yearly or Weekdays should be set.
+type CalendarRule struct {
+	Name     string         `json:"name"`
+	Date     string         `json:"date,omitempty"`     // YYYY-MM-DD, a single date
+	To       string         `json:"to,omitempty"`       // YYYY-MM-DD, makes Date the start of a range, inclusive
+	Yearly   string         `json:"yearly,omitempty"`   // MM-DD, the same date every year
+	Weekdays []time.Weekday `json:"weekdays,omitempty"` // every week on these days, 0 = Sunday
+	Double   bool           `json:"double"`             // if true, this is a double points day instead of a day off
+}
+
+// Calendar holds the days off and double points days for a channel
+type Calendar struct {
+	Rules []CalendarRule `json:"rules"`
+}
+
+func (r CalendarRule) validate() error {
+	set := 0
+	if r.Date != "" {
+		set++
+		if _, err := time.Parse(dateFmt, r.Date); err != nil {
+			return fmt.Errorf("%q: invalid date: %w", r.Name, err)
+		}
+		if r.To != "" {
+			if _, err := time.Parse(dateFmt, r.To); err != nil {
+				return fmt.Errorf("%q: invalid end date: %w", r.Name, err)
+			}
+		}
+	}
+	if r.Yearly != "" {
+		set++
+		if _, err := time.Parse(yearlyFmt, r.Yearly); err != nil {
+			return fmt.Errorf("%q: invalid yearly date: %w", r.Name, err)
+		}
+	}
+	if len(r.Weekdays) > 0 {
+		set++
+		for _, wd := range r.Weekdays {
+			if wd < time.Sunday || wd > time.Saturday {
+				return fmt.Errorf("%q: invalid weekday: %d", r.Name, wd)
+			}
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("%q: exactly one of date, yearly or weekdays must be set", r.Name)
+	}
+	return nil
+}
+
+// matches returns true if the rule covers the day of t
+func (r CalendarRule) matches(t time.Time) bool {
+	date := t.Format(dateFmt)
+	switch {
+	case r.Date != "":
+		if r.To == "" {
+			return date == r.Date
+		}
+		// dates in this format sort the same as strings
+		return date >= r.Date && date <= r.To
+	case r.Yearly != "":
+		return t.Format(yearlyFmt) == r.Yearly
+	default:
+		for _, wd := range r.Weekdays {
+			if t.Weekday() == wd {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// find returns the first rule covering the day of t, with the given value for Double
+func (cal *Calendar) find(t time.Time, double bool) (CalendarRule, bool) {
+	if cal == nil {
+		return CalendarRule{}, false
+	}
+	for _, r := range cal.Rules {
+		if r.Double == double && r.matches(t) {
+			return r, true
+		}
+	}
+	return CalendarRule{}, false
+}
+
+func (cal *Calendar) validate() error {
+	for _, r := range cal.Rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseICal reads the all day events from an iCalendar file. Recurring events are supported
+// with FREQ=YEARLY, or FREQ=WEEKLY with or without BYDAY. Events with the category DOUBLE
+// become double points days, all others are days off.
+func parseICal(r io.Reader) (*Calendar, error) {
+	// unfold lines first, as long lines are split, with a leading space on continuation lines
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cal := &Calendar{}
+	var (
+		inEvent      bool
+		rule         CalendarRule
+		start, end   time.Time
+		freq, byDays string
+	)
+	weekdays := map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+
+	for _, line := range lines {
+		key, val, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		// strip parameters, like in DTSTART;VALUE=DATE:20231224
+		key, _, _ = strings.Cut(strings.ToUpper(key), ";")
+
+		switch {
+		case key == "BEGIN" && val == "VEVENT":
+			inEvent = true
+			rule, start, end, freq, byDays = CalendarRule{}, time.Time{}, time.Time{}, "", ""
+			continue
+		case !inEvent:
+			continue
+		}
+
+		var err error
+		switch key {
+		case "SUMMARY":
+			rule.Name = val
+		case "CATEGORIES":
+			for _, cat := range strings.Split(val, ",") {
+				if strings.EqualFold(strings.TrimSpace(cat), "double") {
+					rule.Double = true
+				}
+			}
+		case "DTSTART":
+			start, err = parseICalDate(val)
+		case "DTEND":
+			end, err = parseICalDate(val)
+		case "RRULE":
+			for _, part := range strings.Split(val, ";") {
+				k, v, _ := strings.Cut(part, "=")
+				switch k {
+				case "FREQ":
+					freq = v
+				case "BYDAY":
+					byDays = v
+				}
+			}
+		case "END":
+			if val != "VEVENT" {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("%q: event without DTSTART", rule.Name)
+			}
+			switch freq {
+			case "":
+				rule.Date = start.Format(dateFmt)
+				// DTEND is exclusive for all day events
+				if !end.IsZero() && end.After(start.AddDate(0, 0, 1)) {
+					rule.To = end.AddDate(0, 0, -1).Format(dateFmt)
+				}
+			case "YEARLY":
+				rule.Yearly = start.Format(yearlyFmt)
+			case "WEEKLY":
+				if byDays == "" {
+					rule.Weekdays = []time.Weekday{start.Weekday()}
+					break
+				}
+				for _, day := range strings.Split(byDays, ",") {
+					wd, found := weekdays[day]
+					if !found {
+						return nil, fmt.Errorf("%q: unsupported BYDAY: %s", rule.Name, day)
+					}
+					rule.Weekdays = append(rule.Weekdays, wd)
+				}
+			default:
+				return nil, fmt.Errorf("%q: unsupported FREQ: %s", rule.Name, freq)
+			}
+			cal.Rules = append(cal.Rules, rule)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", rule.Name, err)
+		}
+	}
+	return cal, nil
+}
+
+// parseICalDate parses a DATE or DATE-TIME value, keeping only the date
+func parseICalDate(val string) (time.Time, error) {
+	if len(val) < len(icalFmt) {
+		return time.Time{}, fmt.Errorf("invalid date: %q", val)
+	}
+	return time.Parse(icalFmt, val[:len(icalFmt)])
+}
+
+// loadCalendarFile loads a calendar from an iCalendar file if the extension is .ics, or JSON otherwise
+func loadCalendarFile(filename string) (*Calendar, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cal *Calendar
+	if strings.EqualFold(filepath.Ext(filename), ".ics") {
+		cal, err = parseICal(file)
+	} else {
+		cal = &Calendar{}
+		err = json.NewDecoder(file).Decode(cal)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cal.validate(); err != nil {
+		return nil, err
+	}
+	return cal, nil
+}
+
+// getCalendar returns the calendar for the channel, loading it from CalendarFile on first use.
+// Returns nil if no calendar is set.
+func (c *Channel) getCalendar() *Calendar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calendar != nil || c.CalendarFile == "" {
+		return c.calendar
+	}
+	cal, err := loadCalendarFile(c.CalendarFile)
+	if err != nil {
+		c.l.Error().
+			Err(err).
+			Str("func", "getCalendar").
+			Str("filename", c.CalendarFile).
+			Msg("Error loading calendar")
+		// don't try again until the next reload
+		c.calendar = &Calendar{}
+		return nil
+	}
+	c.calendar = cal
+	return cal
+}
+
+// dayOff returns the name of the day and true if the game is off for the day of t
+func (c *Channel) dayOff(t time.Time) (string, bool) {
+	r, found := c.getCalendar().find(t, false)
+	return r.Name, found
+}
+
+// doublePoints returns the name of the day and true if rank points are doubled for the day of t
+func (c *Channel) doublePoints(t time.Time) (string, bool) {
+	r, found := c.getCalendar().find(t, true)
+	return r.Name, found
+}
@@ -0,0 +1,142 @@
+package leet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testICal = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+SUMMARY:Christmas
+DTSTART;VALUE=DATE:20231224
+DTEND;VALUE=DATE:20231227
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:National Day
+DTSTART;VALUE=DATE:20230517
+RRULE:FREQ=YEARLY
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Weekend
+DTSTART;VALUE=DATE:20230107
+RRULE:FREQ=WEEKLY;BYDAY=SA,SU
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Leet
+  day
+DTSTART:20230113T000000Z
+CATEGORIES:FUN,DOUBLE
+END:VEVENT
+END:VCALENDAR
+`
+
+func mustParseDate(t *testing.T, date string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(dateFmt, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestParseICal(t *testing.T) {
+	cal, err := parseICal(strings.NewReader(testICal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cal.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.Rules) != 4 {
+		t.Fatalf("Expected 4 rules, got %d", len(cal.Rules))
+	}
+
+	tests := []struct {
+		date   string
+		double bool
+		name   string
+	}{
+		{"2023-12-24", false, "Christmas"},
+		{"2023-12-26", false, "Christmas"},
+		{"2023-12-27", false, ""}, // Wednesday, after DTEND
+		{"2030-05-17", false, "National Day"},
+		{"2024-03-09", false, "Weekend"},
+		{"2023-01-13", true, "Leet day"},
+		{"2023-01-13", false, ""},
+	}
+	for _, tt := range tests {
+		r, found := cal.find(mustParseDate(t, tt.date), tt.double)
+		if found != (tt.name != "") || r.Name != tt.name {
+			t.Errorf("Expected %q for %s (double: %t), got %q (found: %t)", tt.name, tt.date, tt.double, r.Name, found)
+		}
+	}
+}
+
+func TestParseICalUnsupported(t *testing.T) {
+	ical := "BEGIN:VEVENT\nSUMMARY:Monthly\nDTSTART:20230101\nRRULE:FREQ=MONTHLY\nEND:VEVENT\n"
+	if _, err := parseICal(strings.NewReader(ical)); err == nil {
+		t.Errorf("Expected error for unsupported FREQ")
+	}
+}
+
+func TestCalendarRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule  CalendarRule
+		valid bool
+	}{
+		{CalendarRule{Date: "2023-12-24"}, true},
+		{CalendarRule{Date: "2023-12-24", To: "2023-12-26"}, true},
+		{CalendarRule{Yearly: "05-17"}, true},
+		{CalendarRule{Weekdays: []time.Weekday{time.Saturday}}, true},
+		{CalendarRule{}, false},
+		{CalendarRule{Date: "24.12.2023"}, false},
+		{CalendarRule{Yearly: "13-01"}, false},
+		{CalendarRule{Weekdays: []time.Weekday{7}}, false},
+		{CalendarRule{Date: "2023-12-24", Yearly: "12-24"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.validate(); (err == nil) != tt.valid {
+			t.Errorf("Expected valid to be %t for %+v, got error: %v", tt.valid, tt.rule, err)
+		}
+	}
+}
+
+func TestChannelCalendarFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "calendar.json")
+	data := `{"rules": [{"name": "Christmas Eve", "yearly": "12-24"}, {"name": "Fridays", "weekdays": [5], "double": true}]}`
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newScoreData().get(testChannel)
+	c.CalendarFile = filename
+
+	if name, off := c.dayOff(mustParseDate(t, "2023-12-24")); !off || name != "Christmas Eve" {
+		t.Errorf("Expected Christmas Eve to be a day off, got %q, %t", name, off)
+	}
+	if _, off := c.dayOff(mustParseDate(t, "2023-12-22")); off {
+		t.Errorf("Did not expect a day off on a Friday")
+	}
+	if name, double := c.doublePoints(mustParseDate(t, "2023-12-22")); !double || name != "Fridays" {
+		t.Errorf("Expected double points on a Friday, got %q, %t", name, double)
+	}
+}
+
+func TestCalcScoreDoublePoints(t *testing.T) {
+	sd, c := getSeededChannel(1337)
+	c.setInspectAlways(false)
+	c.InspectionTax = 0
+	c.calendar = &Calendar{Rules: []CalendarRule{{Name: "Today", Date: time.Now().Format(dateFmt), Double: true}}}
+
+	msg := sd.calcScore(c)
+	if !strings.Contains(msg, "Double points for Today!") {
+		t.Errorf("Expected double points announcement in: %s", msg)
+	}
+	// Oddlid was first of 4, and should get 8 instead of 4
+	if score := c.get("Oddlid").getScore(); score != 108 {
+		t.Errorf("Expected 108 points, got %d", score)
+	}
+}
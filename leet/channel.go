@@ -1,9 +1,9 @@
 package leet
 
 import (
-	"fmt"
 	"math/rand"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -11,15 +11,34 @@ import (
 
 type Channel struct {
 	l             zerolog.Logger
-	Users         UserMap  `json:"users"`                  // string key is nick
-	Name          string   `json:"channel_name,omitempty"` // we need to duplicate this from the parent map key, so that the instance knows its own name
-	tmpNicks      []string // used for storing who participated in a specific round. Reset after calculation.
-	InspectionTax float64  `json:"inspection_tax"` // percentage, but no check if outside of 0-100
-	OvershootTax  int      `json:"overshoot_tax"`  // interval for how much to deduct if user scores past target
+	Users         UserMap            `json:"users"`                   // string key is nick
+	Name          string             `json:"channel_name,omitempty"`  // we need to duplicate this from the parent map key, so that the instance knows its own name
+	History       []RoundRecord      `json:"history,omitempty"`       // the latest rounds, with what's needed to replay taxation
+	Committed     *CommittedSeed     `json:"committed,omitempty"`     // seed and commitment for the next round, until it's done
+	TaxPolicies   []TaxPolicyConfig  `json:"tax_policies,omitempty"`  // extra taxes, run in order after inspection and overshoot tax
+	Tournament    *Tournament        `json:"tournament,omitempty"`    // optional head-to-head bracket
+	Teams         TeamMap            `json:"teams,omitempty"`         // string key is team name
+	Reminders     *ReminderConfig    `json:"reminders,omitempty"`     // countdown and teaser announcements, off if not set
+	CalendarFile  string             `json:"calendar_file,omitempty"` // iCalendar (.ics) or JSON file with days off and double points days
+	calendar      *Calendar          // loaded from CalendarFile on first use
+	Language      string             `json:"language,omitempty"`      // language code for messages, e.g. "no" or "sv". English if empty.
+	MessagesFile  string             `json:"messages_file,omitempty"` // JSON file with message templates overriding the language
+	templates     *template.Template // built from Language and MessagesFile on first use
+	Render        string             `json:"render,omitempty"` // how to show results and stats: "verbose" (default), "compact" or "private"
+	tmpNicks      []string           // used for storing who participated in a specific round. Reset after calculation.
+	seeded        bool               // if seed is set for the current round. Reset after calculation.
+	seedFunc      func() int64       // returns the seed for each new round. Uses newSeed() if nil. Set in tests for predictable results.
+	seed          int64              // the seed for the random source for the current round
+	commitment    string             // published hash of seed, if CommitReveal is set and the round was committed to in advance
+	InspectionTax float64            `json:"inspection_tax"` // percentage, but no check if outside of 0-100
+	OvershootTax  int                `json:"overshoot_tax"`  // interval for how much to deduct if user scores past target
 	mu            sync.RWMutex
-	InspectAlways bool `json:"inspect_always"` // if false, only inspect if random value between 0 and 6 matches current weekday
-	TaxLoners     bool `json:"tax_loners"`     // If to inspect and tax when only one contestant in a round
-	PostTaxFail   bool `json:"post_tax_fail"`  // If to post to channel why taxation does NOT happen
+	InspectAlways bool `json:"inspect_always"`    // if false, only inspect if random value between 0 and 6 matches current weekday
+	TaxLoners     bool `json:"tax_loners"`        // If to inspect and tax when only one contestant in a round
+	PostTaxFail   bool `json:"post_tax_fail"`     // If to post to channel why taxation does NOT happen
+	CommitReveal  bool `json:"commit_reveal"`     // If to publish a hash of the seed before each round, and reveal the seed after
+	Global        bool `json:"global"`            // If the channel is part of the global leaderboard
+	RetireAfter   int  `json:"retire_after_days"` // Days without entry before a user is retired and hidden from stats. 0 to disable.
 }
 
 func (c *Channel) get(nick string) *User {
@@ -46,14 +65,6 @@ func (c *Channel) nickList() []string {
 	return nicks
 }
 
-func (c *Channel) postTaxFail(msg string) error {
-	if !c.PostTaxFail {
-		return fmt.Errorf("configured to NOT post tax fail")
-	}
-
-	return msgChan(c.Name, msg)
-}
-
 func (c *Channel) hasPendingScores() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -61,6 +72,12 @@ func (c *Channel) hasPendingScores() bool {
 }
 
 func (c *Channel) addNickForRound(nick string) int {
+	c.mu.RLock()
+	seeded := c.seeded
+	c.mu.RUnlock()
+	if !seeded { // first in the round, and not committed to in advance
+		c.seedRound()
+	}
 	// first in gets the most points, last the least
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -71,6 +88,84 @@ func (c *Channel) addNickForRound(nick string) int {
 func (c *Channel) clearNicksForRound() {
 	c.mu.Lock()
 	c.tmpNicks = nil
+	c.seeded = false
+	c.commitment = ""
+	c.Committed = nil
+	c.mu.Unlock()
+}
+
+// seedRound sets a new seed for the round, and returns it
+func (c *Channel) seedRound() int64 {
+	seed := int64(0)
+	if c.seedFunc != nil {
+		seed = c.seedFunc()
+	} else {
+		seed = newSeed()
+	}
+	c.mu.Lock()
+	c.seed = seed
+	c.seeded = true
+	c.commitment = ""
+	c.Committed = nil
+	c.mu.Unlock()
+	c.l.Debug().
+		Str("func", "seedRound").
+		Int64("seed", seed).
+		Msg("Seeded random source for round")
+	return seed
+}
+
+// getRand returns a new random source from the seed for the current round, so it draws
+// the same values each time, as when replayed from the history
+func (c *Channel) getRand() *rand.Rand {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	//nolint:gosec // sufficient, and we need it to be reproducible
+	return rand.New(rand.NewSource(c.seed))
+}
+
+// commitRound seeds a new round and returns the commitment for the seed, to be published before the round starts
+func (c *Channel) commitRound() string {
+	seed := c.seedRound()
+	commitment := seedCommitment(seed)
+	c.mu.Lock()
+	c.commitment = commitment
+	c.Committed = &CommittedSeed{Time: time.Now(), Commitment: commitment, Seed: seed}
+	c.mu.Unlock()
+	return commitment
+}
+
+// restoreCommitment picks up the seed committed to before the score file was saved, unless
+// it's too old to be for the current round
+func (c *Channel) restoreCommitment(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Committed == nil {
+		return
+	}
+	if now.Sub(c.Committed.Time) >= 24*time.Hour {
+		c.Committed = nil
+		return
+	}
+	c.seed = c.Committed.Seed
+	c.seeded = true
+	c.commitment = c.Committed.Commitment
+}
+
+// getCommitment returns the commitment for the current round, or an empty string if not committed
+func (c *Channel) getCommitment() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.commitment
+}
+
+// addHistory saves the record for a finished round, dropping the oldest if we have too many
+func (c *Channel) addHistory(rec RoundRecord) {
+	c.mu.Lock()
+	c.History = append(c.History, rec)
+	if len(c.History) > maxHistory {
+		c.History = c.History[len(c.History)-maxHistory:]
+	}
 	c.mu.Unlock()
 }
 
@@ -159,36 +254,32 @@ func (c *Channel) punishOverShooters(limit int, umap UserMap) UserMap {
 	return umap
 }
 
-func (c *Channel) getMaxRoundTax() float64 {
+// getMaxRoundTax returns the most a user can be taxed in the round, or 0 and the reason
+// for no tax if there won't be any
+func (c *Channel) getMaxRoundTax() (float64, string) {
 	llog := c.l.With().Str("func", "getMaxRoundTax").Logger()
 
 	if c.InspectionTax <= 0.0 { // use as a way to disable this functionality
 		llog.Debug().
 			Float64("InspectionTax", c.InspectionTax).
 			Msg("Negative or zero InspectionTax, bailing out")
-		return 0
+		return 0, ""
 	}
 	lowestTotal := c.getLowestTotalInRound()
 	if lowestTotal < 1 {
 		llog.Debug().
 			Int("lowestTotal", lowestTotal).
 			Msg("Lowest total is below 1, bailing out")
-		if err := c.postTaxFail("No tax today, as we have a participant with less than 1 points"); err != nil {
-			llog.Error().Err(err).Send()
-		}
-		return 0
+		return 0, c.msg("no_tax_low_total", nil)
 	}
 	maxTax := (float64(lowestTotal) / 100.0) * c.InspectionTax
 	if maxTax < 0.0 {
 		llog.Debug().
 			Float64("maxTax", maxTax).
 			Msg("Calculated tax points is negative, bailing out")
-		if err := c.postTaxFail(fmt.Sprintf("No tax today: Lowest total = %d, Percent = %f - which amounts to %f", lowestTotal, c.InspectionTax, maxTax)); err != nil {
-			llog.Error().Err(err).Send()
-		}
-		return 0
+		return 0, c.msg("no_tax_negative", tdata{"Lowest": lowestTotal, "Percent": c.InspectionTax, "MaxTax": maxTax})
 	}
-	return maxTax
+	return maxTax, ""
 }
 
 // 2021-03-09 22:14
@@ -221,59 +312,123 @@ func (c *Channel) getTaxLoners() bool {
 	return c.TaxLoners
 }
 
-func (c *Channel) shouldInspect() bool {
+// shouldInspect returns true if the round should be inspected, using rng to compare to the
+// weekday wd if not set to always inspect. If not, the reason is returned, if it's one to tell.
+func (c *Channel) shouldInspect(rng *rand.Rand, wd time.Weekday) (bool, string) {
 	llog := c.l.With().Str("func", "shouldInspect").Logger()
 	// Having this check before the next will override TaxLoners
 	if c.getInspectAlways() {
 		llog.Debug().Msg("Configured to always run inspection")
-		return true
+		return true, ""
 	}
 	// We could have something like this to only tax when more than 1 contestant
 	if c.tmpNicks == nil || (!c.getTaxLoners() && len(c.tmpNicks) < 2) {
 		llog.Debug().Msg("Configured to NOT tax loners")
-		return false
+		return false, ""
 	}
 
-	wd := int(time.Now().Weekday())
-	//nolint:gosec // sufficient
-	rnd := rand.Intn(7) // 7 for number of days in week
-	doInspect := wd == rnd
+	rnd := rng.Intn(7) // 7 for number of days in week
+	doInspect := int(wd) == rnd
 
 	llog.Debug().
-		Int("weekday", wd).
+		Int("weekday", int(wd)).
 		Int("rnd", rnd).
 		Bool("inspect", doInspect).
 		Msg("To inspect or not...")
 
 	if !doInspect {
-		if err := c.postTaxFail(fmt.Sprintf("No tax today :) Weekday = %d, random = %d", wd, rnd)); err != nil {
-			llog.Error().Err(err).Send()
-		}
+		return false, c.msg("no_tax_weekday", tdata{"Weekday": int(wd), "Random": rnd})
 	}
+	return true, ""
+}
 
-	return doInspect
+// inspection is the outcome of the random tax inspection for a round
+type inspection struct {
+	index   int          // in tmpNicks for the taxed user, -1 if the tax would be below 1, or -2 if not inspected
+	tax     int          // how many points minus, if selected
+	weekday time.Weekday // the weekday compared to, for the history
+	maxTax  float64      // only set if we got as far as calculating it
+	noTax   string       // why there was no tax, if there's a reason to tell
 }
 
-// Return index in c.tmpNicks and how many points minus, if selected, otherwise -1 (or -2) and 0
-func (c *Channel) randomInspect() (int, int) {
-	llog := c.l.With().Str("func", "randomInspect").Logger()
-	if !c.shouldInspect() {
+// inspect runs the random tax inspection for the round at now. Nothing is changed, and as the
+// random source starts over from the seed each time, it gives the same result for the round
+// however many times it's called.
+func (c *Channel) inspect(now time.Time) inspection {
+	llog := c.l.With().Str("func", "inspect").Logger()
+	rng := c.getRand()
+	in := inspection{index: -2, weekday: now.Weekday()}
+	var doInspect bool
+	if doInspect, in.noTax = c.shouldInspect(rng, in.weekday); !doInspect {
 		// unique "error" value indicating where this func bailed out
-		return -2, 0
+		return in
 	}
-	maxTax := c.getMaxRoundTax()
-	if maxTax < 1 { // I don't think we've ever reached this section irl
+	in.maxTax, in.noTax = c.getMaxRoundTax()
+	if in.maxTax < 1 { // I don't think we've ever reached this section irl
 		llog.Debug().
-			Float64("maxTax", maxTax).
+			Float64("maxTax", in.maxTax).
 			Msg("Tax below 1, returning")
-		if err := c.postTaxFail(fmt.Sprintf("No tax today. Calculated tax was: %f", maxTax)); err != nil {
-			llog.Error().Err(err).Send()
+		if in.noTax == "" {
+			in.noTax = c.msg("no_tax_below_one", in.maxTax)
 		}
-		return -1, 0
+		in.index = -1
+		return in
 	}
 
-	//nolint:gosec // sufficient
-	return rand.Intn(len(c.tmpNicks)), rand.Intn(int(maxTax) + 1)
+	in.index, in.tax = drawTax(rng, len(c.tmpNicks), in.maxTax)
+	return in
+}
+
+// retireInactive marks users as retired if they have not had an entry for c.RetireAfter days.
+// Winners are never retired. Returns how many users were retired.
+func (c *Channel) retireInactive(now time.Time) int {
+	if c.RetireAfter <= 0 {
+		return 0
+	}
+	limit := now.AddDate(0, 0, -c.RetireAfter)
+	retired := 0
+	for _, u := range c.Users.filterActive() {
+		if u.getLastEntry().Before(limit) {
+			u.setRetired(true)
+			retired++
+			c.l.Info().
+				Str("func", "retireInactive").
+				Str("user", u.Nick).
+				Time("lastEntry", u.getLastEntry()).
+				Msg("Retiring inactive user")
+		}
+	}
+	return retired
+}
+
+// maintain does the daily upkeep for the channel, that should happen even if nobody plays.
+// Returns true if anything was changed.
+func (c *Channel) maintain(now time.Time) bool {
+	// no taxes on days off
+	if _, off := c.dayOff(now); off {
+		return c.retireInactive(now) > 0
+	}
+	tr := &taxRound{now: now}
+	c.applyDecay(tr)
+	return c.retireInactive(now) > 0 || len(tr.entries) > 0
+}
+
+// roundRecord returns the history record for the current round, given the result from inspect
+func (c *Channel) roundRecord(in inspection) RoundRecord {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return RoundRecord{
+		Date:          time.Now(),
+		Nicks:         append([]string(nil), c.tmpNicks...),
+		Seed:          c.seed,
+		Commitment:    c.commitment,
+		MaxTax:        in.maxTax,
+		TaxIndex:      in.index,
+		Tax:           in.tax,
+		Weekday:       in.weekday,
+		InspectAlways: c.InspectAlways,
+		TaxLoners:     c.TaxLoners,
+	}
 }
 
 // Calling this repeatedly might be inefficient and wasteful.
@@ -0,0 +1,155 @@
+package leet
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventType tells what happened in the game
+type EventType int
+
+const (
+	EventEntry        EventType = iota // a user got on time, Points is the rank in the round
+	EventMiss                          // a user was too early or too late, Points is the penalty
+	EventBonus                         // a user hit a bonus, Points is the bonus
+	EventRoundScored                   // a round has been calculated, Message has the results
+	EventTaxApplied                    // a user was taxed, Points is the (negative) amount
+	EventWinnerLocked                  // a user reached the target score, Points is the total
+	EventNoTax                         // there was no tax inspection in the round, Message says why
+	EventNtpOffset                     // the NTP offset was updated, for all channels
+)
+
+var eventNames = map[EventType]string{
+	EventEntry:        "entry",
+	EventMiss:         "miss",
+	EventBonus:        "bonus",
+	EventRoundScored:  "round_scored",
+	EventTaxApplied:   "tax_applied",
+	EventWinnerLocked: "winner_locked",
+	EventNoTax:        "no_tax",
+	EventNtpOffset:    "ntp_offset",
+}
+
+func (et EventType) String() string {
+	if name, found := eventNames[et]; found {
+		return name
+	}
+	return "unknown"
+}
+
+// Event is emitted on the internal bus when something happens in the game
+type Event struct {
+	Time    time.Time
+	Channel string // empty if the event is not for a specific channel
+	Nick    string // empty if the event is not for a specific user
+	Message string // human readable description, ready to post
+	Label   string // extra detail, like the name of the tax policy for EventTaxApplied, or the NTP server
+	Points  int
+	Offset  time.Duration // the NTP offset for EventNtpOffset
+	Type    EventType
+}
+
+// Handler is called with each event, in the order they are emitted
+type Handler func(Event)
+
+type eventBus struct {
+	handlers map[int]Handler
+	nextID   int
+	mu       sync.RWMutex
+}
+
+var _bus = &eventBus{handlers: make(map[int]Handler)}
+
+// Subscribe registers h to be called for all events from the leet game, and returns a
+// func to unsubscribe. Handlers are called synchronously, and should not block.
+func Subscribe(h Handler) func() {
+	return _bus.subscribe(h)
+}
+
+func (b *eventBus) subscribe(h Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = h
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+func (b *eventBus) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	ids := make([]int, 0, len(b.handlers))
+	for id := range b.handlers {
+		ids = append(ids, id)
+	}
+	b.mu.RUnlock()
+	// call in order of subscription, without holding the lock, so handlers may (un)subscribe
+	sort.Ints(ids)
+	for _, id := range ids {
+		b.mu.RLock()
+		h, found := b.handlers[id]
+		b.mu.RUnlock()
+		if found {
+			h(e)
+		}
+	}
+}
+
+func publish(e Event) {
+	_bus.publish(e)
+}
+
+// ircHandler is the default subscriber, posting results and notices to IRC
+func ircHandler(e Event) {
+	switch e.Type {
+	case EventRoundScored, EventNoTax:
+		if err := msgChan(e.Channel, e.Message); err != nil {
+			_log.Error().Err(err).Str("func", "ircHandler").Str("event", e.Type.String()).Send()
+		}
+	case EventNtpOffset:
+		for _, c := range _scoreData.channelList() {
+			if !active(c.Name) {
+				continue
+			}
+			if err := msgChan(c.Name, c.msg("ntp_offset", ntpData(e.Label, e.Offset))); err != nil {
+				_log.Error().Err(err).Str("func", "ircHandler").Msgf("Failed to send message to channel %q", c.Name)
+			}
+		}
+	}
+}
+
+// ntpData is the data for the ntp_offset message
+func ntpData(server string, offset time.Duration) tdata {
+	return tdata{"Server": server, "Offset": offset}
+}
+
+// publishTax emits EventTaxApplied for a tax of the given amount
+func (c *Channel) publishTax(nick, label string, amount int) {
+	publish(Event{
+		Type:    EventTaxApplied,
+		Channel: c.Name,
+		Nick:    nick,
+		Label:   label,
+		Points:  -amount,
+		Message: fmt.Sprintf("%s: %s -%d", nick, label, amount),
+	})
+}
+
+// publishLocked emits EventWinnerLocked for a user that just reached the target score
+func (c *Channel) publishLocked(u *User) {
+	publish(Event{
+		Type:    EventWinnerLocked,
+		Channel: c.Name,
+		Nick:    u.Nick,
+		Points:  u.getScore(),
+		Message: fmt.Sprintf("%s is winner #%d!", u.Nick, c.getWinnerRank(u.Nick)+1),
+	})
+}
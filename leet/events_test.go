@@ -0,0 +1,63 @@
+package leet
+
+import (
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	bus := &eventBus{handlers: make(map[int]Handler)}
+	var got []string
+	unsub1 := bus.subscribe(func(e Event) { got = append(got, "first:"+e.Type.String()) })
+	bus.subscribe(func(e Event) { got = append(got, "second:"+e.Type.String()) })
+
+	bus.publish(Event{Type: EventEntry})
+	unsub1()
+	bus.publish(Event{Type: EventMiss})
+
+	expected := []string{"first:entry", "second:entry", "second:miss"}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestCalcScoreEvents(t *testing.T) {
+	sd, c := getSeededChannel(1337)
+	c.Name = "#events" // so we only count events from this test
+
+	taxed := 0
+	locked := 0
+	unsub := Subscribe(func(e Event) {
+		if e.Channel != c.Name {
+			return
+		}
+		switch e.Type {
+		case EventTaxApplied:
+			taxed++
+			if e.Points >= 0 {
+				t.Errorf("Expected negative points for tax, got %d", e.Points)
+			}
+		case EventWinnerLocked:
+			locked++
+		}
+	})
+	defer unsub()
+
+	// Oddlid is first, and will get 4 points
+	c.get("Oddlid").setScore(getTargetScore() - 4)
+	sd.calcScore(c)
+
+	if taxed != 1 {
+		t.Errorf("Expected 1 tax event when always inspecting, got %d", taxed)
+	}
+	if taxedNick := c.History[0].taxedNick(); taxedNick == "Oddlid" {
+		t.Fatalf("Test needs another seed, so that Oddlid is not taxed")
+	}
+	if !c.get("Oddlid").isLocked() || locked != 1 {
+		t.Errorf("Expected Oddlid to be locked, with 1 winner event, got %d", locked)
+	}
+}
@@ -0,0 +1,242 @@
+package leet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Formats for Export
+const (
+	ExportJSON = "json"
+	ExportCSV  = "csv"
+)
+
+// Tables for Export in CSV, one per file, as they have different columns
+const (
+	ExportUsers  = "users"
+	ExportRounds = "rounds"
+)
+
+// exportUser is the current stats for a user, as exported
+type exportUser struct {
+	LastEntry  time.Time `json:"last_entry"`
+	BestEntry  time.Time `json:"best_entry"`
+	Nick       string    `json:"nick"`
+	Points     int       `json:"points"`
+	BonusTimes int       `json:"bonus_times"`
+	BonusTotal int       `json:"bonus_total"`
+	TaxTimes   int       `json:"tax_times"`
+	TaxTotal   int       `json:"tax_total"`
+	Misses     int       `json:"misses"`
+	WinnerRank int       `json:"winner_rank,omitempty"` // starting at 1, 0 if not a winner
+	Locked     bool      `json:"locked"`
+	Retired    bool      `json:"retired"`
+}
+
+// exportRoundUser is the outcome for a user in a round, summed up from the deltas
+type exportRoundUser struct {
+	Nick         string `json:"nick"`
+	Position     int    `json:"position,omitempty"` // place in the round, starting at 1, 0 if not on time
+	Rank         int    `json:"rank"`
+	OvershootTax int    `json:"overshoot_tax"`
+	Tax          int    `json:"tax"`
+	Policy       int    `json:"policy"`
+	Total        int    `json:"total"` // net change in points
+}
+
+type exportRound struct {
+	Date  time.Time         `json:"date"`
+	Users []exportRoundUser `json:"users"`
+	Seed  int64             `json:"seed"`
+}
+
+type exportChannel struct {
+	Name   string        `json:"name"`
+	Users  []exportUser  `json:"users"`
+	Rounds []exportRound `json:"rounds,omitempty"`
+}
+
+// exportUsers returns the stats for all users, sorted by points. Caller must hold c.mu.
+func (c *Channel) exportUsers() []exportUser {
+	us := c.Users.toSlice().sortByPointsDesc()
+	eus := make([]exportUser, 0, len(us))
+	for _, u := range us {
+		eu := exportUser{
+			Nick:       u.Nick,
+			Points:     u.getScore(),
+			LastEntry:  u.getLastEntry(),
+			BestEntry:  u.getBestEntry(),
+			BonusTimes: u.getBonusTimes(),
+			BonusTotal: u.getBonusTotal(),
+			TaxTimes:   u.getTaxTimes(),
+			TaxTotal:   u.getTaxTotal(),
+			Misses:     u.getMissTotal(),
+			Locked:     u.isLocked(),
+			Retired:    u.isRetired(),
+		}
+		if eu.Locked {
+			eu.WinnerRank = c.getWinnerRank(u.Nick) + 1
+		}
+		eus = append(eus, eu)
+	}
+	return eus
+}
+
+// exportRounds returns the outcome of all rounds in history, oldest first. Caller must hold c.mu.
+func (c *Channel) exportRounds() []exportRound {
+	ers := make([]exportRound, 0, len(c.History))
+	for _, rec := range c.History {
+		ers = append(ers, rec.export())
+	}
+	return ers
+}
+
+// export returns the outcome for each user in the round. Rounds recorded before deltas were
+// saved only have the positions, and the tax from the inspection.
+func (r RoundRecord) export() exportRound {
+	er := exportRound{Date: r.Date, Seed: r.Seed}
+	idx := make(map[string]int)
+	get := func(nick string) *exportRoundUser {
+		if i, found := idx[nick]; found {
+			return &er.Users[i]
+		}
+		idx[nick] = len(er.Users)
+		er.Users = append(er.Users, exportRoundUser{Nick: nick})
+		return &er.Users[len(er.Users)-1]
+	}
+	for pos, nick := range r.Nicks {
+		get(nick).Position = pos + 1
+	}
+	if len(r.Deltas) == 0 {
+		if nick := r.taxedNick(); nick != "" {
+			get(nick).Tax = -r.Tax
+			get(nick).Total = -r.Tax
+		}
+		return er
+	}
+	for _, d := range r.Deltas {
+		eru := get(d.Nick)
+		switch d.Reason {
+		case reasonRank:
+			eru.Rank += d.Points
+		case reasonOvershoot:
+			eru.OvershootTax += d.Points
+		case reasonTax:
+			eru.Tax += d.Points
+		case reasonPolicy:
+			eru.Policy += d.Points
+		}
+		eru.Total += d.Points
+	}
+	return er
+}
+
+func (s *ScoreData) exportChannels(channel string) ([]exportChannel, error) {
+	ecs := make([]exportChannel, 0)
+	for _, c := range s.channelList() {
+		if channel != "" && c.Name != channel {
+			continue
+		}
+		c.mu.RLock()
+		ec := exportChannel{Name: c.Name, Users: c.exportUsers(), Rounds: c.exportRounds()}
+		c.mu.RUnlock()
+		ecs = append(ecs, ec)
+	}
+	if len(ecs) == 0 {
+		if channel != "" {
+			return nil, fmt.Errorf("no such channel: %q", channel)
+		}
+		return nil, fmt.Errorf("no channels")
+	}
+	return ecs, nil
+}
+
+// writeUsersCSV writes the stats for all users as one CSV table
+func writeUsersCSV(w io.Writer, ecs []exportChannel) error {
+	cw := csv.NewWriter(w)
+	itoa := strconv.Itoa
+	_ = cw.Write([]string{
+		"channel", "nick", "points", "last_entry", "best_entry", "bonus_times", "bonus_total",
+		"tax_times", "tax_total", "misses", "locked", "winner_rank", "retired",
+	})
+	for _, ec := range ecs {
+		for _, eu := range ec.Users {
+			_ = cw.Write([]string{
+				ec.Name, eu.Nick, itoa(eu.Points), csvTime(eu.LastEntry), csvTime(eu.BestEntry),
+				itoa(eu.BonusTimes), itoa(eu.BonusTotal), itoa(eu.TaxTimes), itoa(eu.TaxTotal),
+				itoa(eu.Misses), strconv.FormatBool(eu.Locked), itoa(eu.WinnerRank), strconv.FormatBool(eu.Retired),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeRoundsCSV writes the outcome for each user in each round as one CSV table
+func writeRoundsCSV(w io.Writer, ecs []exportChannel) error {
+	cw := csv.NewWriter(w)
+	itoa := strconv.Itoa
+	_ = cw.Write([]string{
+		"channel", "date", "nick", "position", "rank", "overshoot_tax", "tax", "policy", "total",
+	})
+	for _, ec := range ecs {
+		for _, er := range ec.Rounds {
+			for _, eru := range er.Users {
+				_ = cw.Write([]string{
+					ec.Name, csvTime(er.Date), eru.Nick, itoa(eru.Position), itoa(eru.Rank),
+					itoa(eru.OvershootTax), itoa(eru.Tax), itoa(eru.Policy), itoa(eru.Total),
+				})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Export writes user stats and round history from the given score file to w, in the given
+// format (ExportJSON or ExportCSV). JSON has both in one document, while CSV has only the
+// given table (ExportUsers or ExportRounds), as they have different columns. The default
+// score file is used if filename is empty, and all channels are exported if channel is empty.
+func Export(w io.Writer, filename, channel, format, table string) error {
+	if format == ExportCSV && table != ExportUsers && table != ExportRounds {
+		return fmt.Errorf("unknown table: %q, use %q or %q", table, ExportUsers, ExportRounds)
+	}
+	if filename == "" {
+		filename = _scoreFile
+	}
+	sd, err := newScoreData().loadFile(filename)
+	if err != nil {
+		return err
+	}
+	ecs, err := sd.exportChannels(channel)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Channels []exportChannel `json:"channels"`
+		}{ecs})
+	case ExportCSV:
+		if table == ExportRounds {
+			return writeRoundsCSV(w, ecs)
+		}
+		return writeUsersCSV(w, ecs)
+	default:
+		return fmt.Errorf("unknown format: %q, use %q or %q", format, ExportJSON, ExportCSV)
+	}
+}
@@ -0,0 +1,100 @@
+package leet
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getExportFile(t *testing.T) string {
+	t.Helper()
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	c.get("alice").setScore(42)
+	c.get("bob").setScore(7)
+	c.get("bob").lock()
+	c.addHistory(RoundRecord{
+		Date:     time.Date(2023, 1, 13, 13, 38, 0, 0, time.UTC),
+		Nicks:    []string{"alice", "bob"},
+		TaxIndex: -2,
+		Deltas: []Delta{
+			{Nick: "alice", Reason: reasonRank, Points: 2},
+			{Nick: "bob", Reason: reasonRank, Points: 1},
+			{Nick: "bob", Reason: reasonTax, Points: -3},
+		},
+	})
+	sd.get("#other").get("carol").setScore(1)
+
+	filename := filepath.Join(t.TempDir(), "scores.json")
+	if err := sd.saveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestExportCSV(t *testing.T) {
+	filename := getExportFile(t)
+	for table, expected := range map[string][]string{
+		ExportUsers: {
+			"channel,nick,points,last_entry,best_entry,bonus_times,bonus_total,tax_times,tax_total,misses,locked,winner_rank,retired",
+			"#blackhole,alice,42,,,0,0,0,0,0,false,0,false",
+			"#blackhole,bob,7,,,0,0,0,0,0,true,1,false",
+		},
+		ExportRounds: {
+			"channel,date,nick,position,rank,overshoot_tax,tax,policy,total",
+			"#blackhole,2023-01-13T13:38:00Z,alice,1,2,0,0,0,2",
+			"#blackhole,2023-01-13T13:38:00Z,bob,2,1,0,-3,0,-2",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := Export(&buf, filename, testChannel, ExportCSV, table); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		if len(lines) != len(expected) {
+			t.Fatalf("Expected %d lines for %s, got %d:\n%s", len(expected), table, len(lines), buf.String())
+		}
+		for i := range expected {
+			if lines[i] != expected[i] {
+				t.Errorf("Expected %s line %d to be %q, got %q", table, i, expected[i], lines[i])
+			}
+		}
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	filename := getExportFile(t)
+	var buf bytes.Buffer
+	if err := Export(&buf, filename, "", ExportJSON, ""); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Channels []exportChannel `json:"channels"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Channels) != 2 || out.Channels[0].Name != "#blackhole" || out.Channels[1].Name != "#other" {
+		t.Fatalf("Expected both channels, sorted, got %+v", out.Channels)
+	}
+	if len(out.Channels[0].Rounds) != 1 || len(out.Channels[1].Rounds) != 0 {
+		t.Errorf("Expected one round for %s only", testChannel)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	filename := getExportFile(t)
+	var buf bytes.Buffer
+	if err := Export(&buf, filename, "#nonexistent", ExportJSON, ""); err == nil {
+		t.Errorf("Expected error for unknown channel")
+	}
+	if err := Export(&buf, filename, "", "xml", ""); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+	if err := Export(&buf, filename, "", ExportCSV, "teams"); err == nil {
+		t.Errorf("Expected error for unknown table")
+	}
+}
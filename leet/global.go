@@ -0,0 +1,146 @@
+package leet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// globalUser is a user aggregated across all channels that have opted in to the global view
+type globalUser struct {
+	nick     string
+	channels []string
+	points   int
+	bonuses  int
+	taxes    int
+	misses   int
+}
+
+// canonical returns the nick a user is known as in the global view
+func (s *ScoreData) canonical(nick string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if alias, found := s.Aliases[nick]; found {
+		return alias
+	}
+	return nick
+}
+
+// globalChannels returns the channels that are part of the global view, sorted by name
+func (s *ScoreData) globalChannels() []*Channel {
+	var cs []*Channel
+	for _, c := range s.channelList() {
+		if c.Global {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// globalChannelNames returns the names of the channels that are part of the global view, sorted
+func (s *ScoreData) globalChannelNames() []string {
+	cs := s.globalChannels()
+	names := make([]string, 0, len(cs))
+	for _, c := range cs {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// globalUsers aggregates users across all global channels, sorted by points
+func (s *ScoreData) globalUsers() []*globalUser {
+	gum := make(map[string]*globalUser)
+	for _, c := range s.globalChannels() {
+		name := c.Name
+		c.mu.RLock()
+		for nick, u := range c.Users {
+			if u.isRetired() {
+				continue
+			}
+			cn := s.canonical(nick)
+			gu, found := gum[cn]
+			if !found {
+				gu = &globalUser{nick: cn}
+				gum[cn] = gu
+			}
+			gu.channels = append(gu.channels, name)
+			gu.points += u.getScore()
+			gu.bonuses += u.getBonusTotal()
+			gu.taxes += u.getTaxTotal()
+			gu.misses += u.getMissTotal()
+		}
+		c.mu.RUnlock()
+	}
+
+	gus := make([]*globalUser, 0, len(gum))
+	for _, gu := range gum {
+		gus = append(gus, gu)
+	}
+	sort.Slice(gus, func(i, j int) bool {
+		if gus[i].points == gus[j].points {
+			return gus[i].nick < gus[j].nick
+		}
+		return gus[i].points > gus[j].points
+	})
+	return gus
+}
+
+// globalStats returns the global leaderboard, in the language of the channel asking for it
+func (s *ScoreData) globalStats(c *Channel) string {
+	gus := s.globalUsers()
+	if len(gus) == 0 {
+		return c.msg("global_none", nil)
+	}
+
+	maxLen := 0
+	for _, gu := range gus {
+		if len(gu.nick) > maxLen {
+			maxLen = len(gu.nick)
+		}
+	}
+
+	var sb strings.Builder
+	c.writeMsg(&sb, "global_header", strings.Join(s.globalChannelNames(), ", "))
+	fmt.Fprintf(&sb, "\n")
+	for _, gu := range gus {
+		c.writeMsg(&sb, "global_line", tdata{
+			"Nick":     gu.nick,
+			"Width":    maxLen,
+			"Points":   gu.points,
+			"Bonuses":  gu.bonuses,
+			"Taxes":    gu.taxes,
+			"Misses":   gu.misses,
+			"Channels": strings.Join(gu.channels, ", "),
+		})
+		fmt.Fprintf(&sb, "\n")
+	}
+	return sb.String()
+}
+
+// playedElsewhere returns the name of another global channel where the given nick, or any
+// of its aliases, already has an entry for the same day as t. Returns an empty string if not.
+func (s *ScoreData) playedElsewhere(channel, nick string, t time.Time) string {
+	if !s.OneChannelPerDay {
+		return ""
+	}
+	if c, found := s.lookup(channel); !found || !c.Global {
+		return ""
+	}
+	cn := s.canonical(nick)
+	for _, c := range s.globalChannels() {
+		name := c.Name
+		if name == channel {
+			continue
+		}
+		c.mu.RLock()
+		for n, u := range c.Users {
+			if s.canonical(n) == cn && sameDay(u.getLastEntry(), t) {
+				c.mu.RUnlock()
+				return name
+			}
+		}
+		c.mu.RUnlock()
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package leet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func getGlobalData() *ScoreData {
+	sd := newScoreData()
+	sd.Aliases = map[string]string{"alice_": "alice"}
+	now := time.Now()
+
+	c1 := sd.get("#floor1")
+	c1.Global = true
+	c1.get("alice").setScore(10)
+	c1.get("alice").setLastEntry(now)
+	c1.get("bob").setScore(5)
+
+	c2 := sd.get("#floor2")
+	c2.Global = true
+	c2.get("alice_").setScore(20)
+
+	c3 := sd.get("#private")
+	c3.get("carol").setScore(100)
+
+	return sd
+}
+
+func TestGlobalUsers(t *testing.T) {
+	sd := getGlobalData()
+	gus := sd.globalUsers()
+
+	if len(gus) != 2 {
+		t.Fatalf("Expected 2 global users, got %d", len(gus))
+	}
+	if gus[0].nick != "alice" || gus[0].points != 30 || len(gus[0].channels) != 2 {
+		t.Errorf("Expected alice with 30 points from 2 channels first, got %+v", gus[0])
+	}
+	c := sd.get("#floor1")
+	stats := sd.globalStats(c)
+	if strings.Contains(stats, "carol") {
+		t.Errorf("Expected user from non-global channel to be left out, got: %q", stats)
+	}
+	if !strings.Contains(stats, "alice : 0030 Bonus: 0000 Tax: -0000 Miss: -0000 (#floor1, #floor2)") {
+		t.Errorf("Unexpected global stats: %q", stats)
+	}
+	empty := newScoreData()
+	c = empty.get("#floor1")
+	c.Language = "no"
+	if stats := empty.globalStats(c); stats != "Ingen kanaler er med i den globale ledertavlen" {
+		t.Errorf("Expected Norwegian when no channels are global, got: %q", stats)
+	}
+}
+
+func TestPlayedElsewhere(t *testing.T) {
+	sd := getGlobalData()
+	now := time.Now()
+
+	if other := sd.playedElsewhere("#floor2", "alice_", now); other != "" {
+		t.Errorf("Expected no restriction when not enabled, got %q", other)
+	}
+
+	sd.OneChannelPerDay = true
+	if other := sd.playedElsewhere("#floor2", "alice_", now); other != "#floor1" {
+		t.Errorf("Expected alias to have played in #floor1, got %q", other)
+	}
+	if other := sd.playedElsewhere("#floor2", "alice_", now.AddDate(0, 0, 1)); other != "" {
+		t.Errorf("Expected no restriction the next day, got %q", other)
+	}
+	if other := sd.playedElsewhere("#floor2", "bob", now); other != "" {
+		t.Errorf("Expected bob to be free to play, got %q", other)
+	}
+	if other := sd.playedElsewhere("#private", "alice", now); other != "" {
+		t.Errorf("Expected no restriction in non-global channel, got %q", other)
+	}
+}
@@ -0,0 +1,100 @@
+package leet
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Health is the state of the background jobs for the game, for health checks
+type Health struct {
+	LastSave     time.Time `json:"last_save"`
+	SaveError    string    `json:"save_error,omitempty"`
+	CronEntries  int       `json:"cron_entries"`
+	NextRun      time.Time `json:"next_run"`
+	NtpServer    string    `json:"ntp_server,omitempty"`
+	NtpOffset    string    `json:"ntp_offset"`
+	LastNtpCheck time.Time `json:"last_ntp_check"`
+	NtpError     string    `json:"ntp_error,omitempty"`
+}
+
+// ntpStatus is the outcome of the latest NTP query
+type ntpStatus struct {
+	last time.Time
+	err  error
+	mu   sync.RWMutex
+}
+
+var _ntpStatus ntpStatus
+
+func (ns *ntpStatus) set(err error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.last = time.Now()
+	ns.err = err
+}
+
+func (ns *ntpStatus) get() (time.Time, error) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.last, ns.err
+}
+
+func (s *ScoreData) setSaveResult(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSaveErr = err
+	if err == nil {
+		s.lastSave = time.Now()
+	}
+}
+
+func (s *ScoreData) saveResult() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSave, s.lastSaveErr
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// health returns the state of saves, cron and NTP, and an error if the latest save failed,
+// or nothing is scheduled. A failed NTP query is only reported, as the game works without.
+func (s *ScoreData) health() (Health, error) {
+	h := Health{
+		NtpServer: _ntpServer,
+		NtpOffset: _ntpOffset.String(),
+	}
+	var saveErr, ntpErr error
+	h.LastSave, saveErr = s.saveResult()
+	h.SaveError = errString(saveErr)
+	h.LastNtpCheck, ntpErr = _ntpStatus.get()
+	h.NtpError = errString(ntpErr)
+
+	if _cron != nil {
+		for _, e := range _cron.Entries() {
+			h.CronEntries++
+			if h.NextRun.IsZero() || (!e.Next.IsZero() && e.Next.Before(h.NextRun)) {
+				h.NextRun = e.Next
+			}
+		}
+	}
+
+	switch {
+	case saveErr != nil:
+		return h, errors.New("latest save failed: " + saveErr.Error())
+	case h.CronEntries == 0:
+		return h, errors.New("no cron jobs scheduled")
+	}
+	return h, nil
+}
+
+// HealthCheck returns the state of the background jobs for the game, and an error if
+// something is wrong
+func HealthCheck() (any, error) {
+	return _scoreData.health()
+}
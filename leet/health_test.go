@@ -0,0 +1,29 @@
+package leet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	sd := newScoreData()
+
+	if err := sd.saveFile(filepath.Join(t.TempDir(), "nope", "scores.json")); err == nil {
+		t.Fatal("Expected save to a missing dir to fail")
+	}
+	h, err := sd.health()
+	if err == nil || h.SaveError == "" || !h.LastSave.IsZero() {
+		t.Errorf("Expected unhealthy after failed save, got %v: %+v", err, h)
+	}
+
+	if err := sd.saveFile(filepath.Join(t.TempDir(), "scores.json")); err != nil {
+		t.Fatal(err)
+	}
+	h, err = sd.health()
+	if h.SaveError != "" || h.LastSave.IsZero() {
+		t.Errorf("Expected successful save to be reported, got %+v", h)
+	}
+	if h.CronEntries > 0 && err != nil {
+		t.Errorf("Expected healthy with %d cron entries, got %v", h.CronEntries, err)
+	}
+}
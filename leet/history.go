@@ -0,0 +1,136 @@
+package leet
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+const maxHistory = 366 // how many rounds to keep per channel
+
+// RoundRecord is saved to the channel history after each round.
+// Together with the seed, it holds all input needed to replay the tax inspection
+// for the round, so that the outcome can be verified afterwards.
+type RoundRecord struct {
+	Date          time.Time    `json:"date"`
+	Nicks         []string     `json:"nicks"`                // contestants on time, in order of entry
+	Seed          int64        `json:"seed"`                 // seed for the random source used for the round
+	Commitment    string       `json:"commitment,omitempty"` // hash of Seed published before the round, if CommitReveal is set for the channel
+	MaxTax        float64      `json:"max_tax"`              // only set if we got as far as calculating it
+	TaxIndex      int          `json:"tax_index"`            // index in Nicks for the taxed user, or the negative value from inspect
+	Tax           int          `json:"tax"`                  // how much the selected user was taxed
+	Weekday       time.Weekday `json:"weekday"`              // the weekday the random value was compared to
+	InspectAlways bool         `json:"inspect_always"`       // channel setting at the time of the round
+	TaxLoners     bool         `json:"tax_loners"`           // channel setting at the time of the round
+	Deltas        []Delta      `json:"deltas,omitempty"`     // all changes in points in the round, with the reason for each
+}
+
+// newSeed returns a seed for a round. We use crypto/rand here, so that the seed can't
+// be guessed from the time of the round.
+func newSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// seedCommitment returns the hash to publish before a round, so that players can verify
+// afterwards that the revealed seed was decided before anyone entered.
+// Anyone can check it with e.g.: echo -n <seed> | sha256sum
+func seedCommitment(seed int64) string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(seed, 10)))
+	return hex.EncodeToString(sum[:])
+}
+
+// CommittedSeed is the seed for the next round, saved with the channel from when the
+// commitment is published until the round is done, so a restart in between doesn't lose it
+type CommittedSeed struct {
+	Time       time.Time `json:"time"` // when the commitment was published
+	Commitment string    `json:"commitment"`
+	Seed       int64     `json:"seed"`
+}
+
+// drawTax picks the index of the user to tax, and the tax amount.
+// Shared between Channel.inspect and RoundRecord.replay, so they can't drift apart.
+func drawTax(rng *rand.Rand, numNicks int, maxTax float64) (int, int) {
+	return rng.Intn(numNicks), rng.Intn(int(maxTax) + 1)
+}
+
+// replay runs the inspection for the round again from the recorded seed, and returns
+// the same values as Channel.inspect did.
+func (r RoundRecord) replay() (int, int) {
+	//nolint:gosec // we need it to be reproducible
+	rng := rand.New(rand.NewSource(r.Seed))
+	if !r.InspectAlways {
+		if !r.TaxLoners && len(r.Nicks) < 2 {
+			return -2, 0
+		}
+		if rng.Intn(7) != int(r.Weekday) {
+			return -2, 0
+		}
+	}
+	if r.MaxTax < 1 {
+		return -1, 0
+	}
+	return drawTax(rng, len(r.Nicks), r.MaxTax)
+}
+
+// verify returns true if replaying the round gives the same result as recorded
+func (r RoundRecord) verify() bool {
+	idx, tax := r.replay()
+	return idx == r.TaxIndex && tax == r.Tax
+}
+
+// taxedNick returns the nick of the user selected for taxation, or an empty string if none
+func (r RoundRecord) taxedNick() string {
+	if r.TaxIndex < 0 || r.TaxIndex >= len(r.Nicks) {
+		return ""
+	}
+	return r.Nicks[r.TaxIndex]
+}
+
+// verifyCommitment returns true if the round was not committed to, or if the seed matches the commitment
+func (r RoundRecord) verifyCommitment() bool {
+	return r.Commitment == "" || r.Commitment == seedCommitment(r.Seed)
+}
+
+// findRound returns the latest round in history for the given date (YYYY-MM-DD), or the latest round
+// if date is empty
+func (c *Channel) findRound(date string) (RoundRecord, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.History) - 1; i >= 0; i-- {
+		if date == "" || c.History[i].Date.Format("2006-01-02") == date {
+			return c.History[i], true
+		}
+	}
+	return RoundRecord{}, false
+}
+
+// verifyRound replays a round from history, and returns a report on whether it checks out
+func (c *Channel) verifyRound(date string) string {
+	rec, found := c.findRound(date)
+	if !found {
+		if date == "" {
+			return c.msg("verify_no_rounds", nil)
+		}
+		return c.msg("verify_not_found", date)
+	}
+
+	data := tdata{
+		"Date":       rec.Date.Format("2006-01-02"),
+		"Seed":       rec.Seed,
+		"Commitment": rec.Commitment,
+		"OK":         rec.verify() && rec.verifyCommitment(),
+	}
+	if idx, tax := rec.replay(); idx >= 0 && idx < len(rec.Nicks) {
+		data["Nick"] = rec.Nicks[idx]
+		data["Tax"] = tax
+	}
+	return c.msg("verify_report", data)
+}
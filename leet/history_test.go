@@ -0,0 +1,171 @@
+package leet
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getSeededChannel(seed int64) (*ScoreData, *Channel) {
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	c.seedFunc = func() int64 { return seed }
+	c.InspectionTax = 50.0
+	c.setInspectAlways(true)
+	for idx, nick := range []string{"Oddlid", "Snelhest", "Tord", "bAAAArd"} {
+		c.get(nick).setScore(100 + idx*10)
+		c.addNickForRound(nick)
+	}
+	return sd, c
+}
+
+func TestSeededInspection(t *testing.T) {
+	const seed int64 = 1337
+
+	_, c := getSeededChannel(seed)
+	in := c.inspect(time.Now())
+	idx, tax := in.index, in.tax
+
+	// lowest total is 100, and 50% of that gives max tax 50
+	//nolint:gosec // test
+	rng := rand.New(rand.NewSource(seed))
+	expIdx, expTax := rng.Intn(4), rng.Intn(51)
+	if idx != expIdx || tax != expTax {
+		t.Errorf("Expected index %d and tax %d, got %d and %d", expIdx, expTax, idx, tax)
+	}
+
+	// the same seed should give the same result every time
+	_, c = getSeededChannel(seed)
+	in = c.inspect(time.Now())
+	idx2, tax2 := in.index, in.tax
+	if idx != idx2 || tax != tax2 {
+		t.Errorf("Expected same result for same seed, got %d/%d and %d/%d", idx, tax, idx2, tax2)
+	}
+}
+
+func TestRoundRecordReplay(t *testing.T) {
+	_, c := getSeededChannel(42)
+	c.setInspectAlways(false)
+	c.setTaxLoners(true)
+
+	for i := 0; i < 20; i++ {
+		in := c.inspect(time.Now())
+		rec := c.roundRecord(in)
+		if !rec.verify() {
+			rrIdx, rrTax := rec.replay()
+			t.Errorf("Replay gave %d/%d, but recorded was %d/%d", rrIdx, rrTax, in.index, in.tax)
+		}
+		c.seedFunc = func() int64 { return int64(i) }
+		c.seedRound()
+	}
+}
+
+func TestCalcScoreHistory(t *testing.T) {
+	sd, c := getSeededChannel(1337)
+
+	sd.calcScore(c)
+
+	if len(c.History) != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", len(c.History))
+	}
+	rec := c.History[0]
+	if rec.Seed != 1337 {
+		t.Errorf("Expected seed 1337, got %d", rec.Seed)
+	}
+	if len(rec.Nicks) != 4 {
+		t.Errorf("Expected 4 nicks in record, got %d", len(rec.Nicks))
+	}
+	if !rec.verify() {
+		t.Errorf("Recorded round does not verify: %+v", rec)
+	}
+	if rec.taxedNick() == "" {
+		t.Errorf("Expected a taxed nick when always inspecting")
+	}
+}
+
+func TestCommitReveal(t *testing.T) {
+	sd, c := getSeededChannel(1337)
+	c.CommitReveal = true
+
+	commitment := c.commitRound()
+	if commitment != seedCommitment(1337) {
+		t.Errorf("Expected commitment for seed 1337, got %q", commitment)
+	}
+
+	res := sd.calcScore(c)
+	if !strings.Contains(res, "seed for this round: 1337") {
+		t.Errorf("Expected seed to be revealed in results, got: %q", res)
+	}
+	if c.getCommitment() != "" {
+		t.Errorf("Expected commitment to be cleared after the round")
+	}
+
+	rec, found := c.findRound("")
+	if !found {
+		t.Fatal("Expected to find round in history")
+	}
+	if rec.Commitment != commitment || !rec.verifyCommitment() {
+		t.Errorf("Recorded commitment %q does not match published %q", rec.Commitment, commitment)
+	}
+
+	report := c.verifyRound(rec.Date.Format("2006-01-02"))
+	if !strings.HasSuffix(report, "OK") {
+		t.Errorf("Expected round to verify, got: %q", report)
+	}
+
+	// tamper with the seed, and make sure it's detected
+	c.History[len(c.History)-1].Seed++
+	report = c.verifyRound("")
+	if !strings.HasSuffix(report, "MISMATCH!") {
+		t.Errorf("Expected mismatch after changing seed, got: %q", report)
+	}
+}
+
+func TestCommitmentRestart(t *testing.T) {
+	sd, c := getSeededChannel(1337)
+	c.CommitReveal = true
+	commitment := c.commitRound()
+
+	var buf bytes.Buffer
+	if _, err := sd.save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.String()
+
+	restarted := newScoreData()
+	if err := restarted.load(strings.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	rc := restarted.get(testChannel)
+	if rc.getCommitment() != commitment || rc.seed != 1337 || !rc.seeded {
+		t.Fatalf("Expected the committed seed restored, got seed %d and commitment %q", rc.seed, rc.getCommitment())
+	}
+	rc.addNickForRound("alice")
+	if rc.seed != 1337 {
+		t.Errorf("Expected the first entry to keep the committed seed, got %d", rc.seed)
+	}
+
+	// one from an earlier round is dropped
+	c.Committed.Time = c.Committed.Time.Add(-25 * time.Hour)
+	buf.Reset()
+	if _, err := sd.save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restarted = newScoreData()
+	if err := restarted.load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if rc := restarted.get(testChannel); rc.seeded || rc.getCommitment() != "" || rc.Committed != nil {
+		t.Errorf("Expected stale commitment dropped")
+	}
+
+	res := sd.calcScore(c)
+	if c.Committed != nil {
+		t.Errorf("Expected committed seed cleared after the round")
+	}
+	if !strings.Contains(res, "commitment: sha256:"+commitment) {
+		t.Errorf("Expected commitment in results, got: %q", res)
+	}
+}
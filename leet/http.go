@@ -0,0 +1,210 @@
+package leet
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiChannelsPath = "/api/channels"
+
+// apiChannel is the summary for a channel, as listed by the HTTP API
+type apiChannel struct {
+	Name    string `json:"name"`
+	Users   int    `json:"users"`
+	Winners int    `json:"winners"`
+	Rounds  int    `json:"rounds"`
+}
+
+// apiHandler serves the leet state over HTTP. All access to the score data goes through
+// the same locks as the bot uses, so it's safe to serve while the game is running.
+type apiHandler struct {
+	s *ScoreData
+}
+
+var scoreboardTmpl = template.Must(template.New("scoreboard").Funcs(template.FuncMap{
+	"inc":  func(i int) int { return i + 1 },
+	"date": getLongDate,
+	"time": func(t time.Time) string { return t.Format("15:04:05.000000000") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Leet scoreboard</title>
+<style>
+body { font-family: monospace; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.2em 1em; text-align: left; }
+tr:nth-child(even) { background: #eee; }
+</style>
+</head>
+<body>
+<h1>Leet scoreboard</h1>
+{{- range .}}
+<h2>{{.Name}}</h2>
+<table>
+<tr><th>#</th><th>Nick</th><th>Points</th><th>Last entry</th><th>Best entry</th><th>Winner</th></tr>
+{{- range $idx, $u := .Users}}
+<tr><td>{{inc $idx}}</td><td>{{$u.Nick}}</td><td>{{$u.Points}}</td><td>{{date $u.LastEntry}}</td><td>{{time $u.BestEntry}}</td><td>{{if $u.WinnerRank}}#{{$u.WinnerRank}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No channels yet</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// RegisterHandlers adds the HTTP API and the scoreboard page for the leet game to mux:
+//
+//	/api/channels                 - all channels, with number of users, winners and rounds
+//	/api/channels/{name}/users    - stats for all users in the channel
+//	/api/channels/{name}/rounds   - round history for the channel, ?limit=N for the latest N
+//	/scoreboard                   - HTML page with the stats for all channels, ?channel=name for one
+//
+// The leading "#" in channel names may be left out.
+func RegisterHandlers(mux *http.ServeMux) {
+	apiHandler{s: _scoreData}.register(mux)
+}
+
+func (h apiHandler) register(mux *http.ServeMux) {
+	mux.HandleFunc(apiChannelsPath, onlyGet(h.channels))
+	mux.HandleFunc(apiChannelsPath+"/", onlyGet(h.channel))
+	mux.HandleFunc("/scoreboard", onlyGet(h.scoreboard))
+}
+
+func onlyGet(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		_log.Error().Err(err).Str("func", "writeJSON").Send()
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+// find returns the channel with the given name, trying with a leading "#" if not found as is
+func (h apiHandler) find(name string) (*Channel, bool) {
+	if c, found := h.s.lookup(name); found {
+		return c, true
+	}
+	if !strings.HasPrefix(name, "#") {
+		return h.s.lookup("#" + name)
+	}
+	return nil, false
+}
+
+func (h apiHandler) channels(w http.ResponseWriter, r *http.Request) {
+	acs := make([]apiChannel, 0)
+	for _, c := range h.s.channelList() {
+		c.mu.RLock()
+		ac := apiChannel{
+			Name:    c.Name,
+			Users:   len(c.Users),
+			Winners: len(c.Users.filterByLocked(true)),
+			Rounds:  len(c.History),
+		}
+		c.mu.RUnlock()
+		acs = append(acs, ac)
+	}
+	writeJSON(w, http.StatusOK, acs)
+}
+
+// channel serves /api/channels/{name}/users and /api/channels/{name}/rounds. We split the path
+// ourselves, since channel names may contain characters that need escaping.
+func (h apiHandler) channel(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, apiChannelsPath+"/")
+	idx := strings.LastIndex(rest, "/")
+	if idx < 1 {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+	name, resource := rest[:idx], rest[idx+1:]
+
+	c, found := h.find(name)
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "no such channel: "+name)
+		return
+	}
+
+	switch resource {
+	case "users":
+		c.mu.RLock()
+		eus := c.exportUsers()
+		c.mu.RUnlock()
+		writeJSON(w, http.StatusOK, eus)
+	case "rounds":
+		limit := 0
+		if val := r.URL.Query().Get("limit"); val != "" {
+			var err error
+			if limit, err = strconv.Atoi(val); err != nil || limit < 0 {
+				writeJSONError(w, http.StatusBadRequest, "invalid limit: "+val)
+				return
+			}
+		}
+		c.mu.RLock()
+		ers := c.exportRounds()
+		c.mu.RUnlock()
+		if limit > 0 && limit < len(ers) {
+			ers = ers[len(ers)-limit:]
+		}
+		writeJSON(w, http.StatusOK, ers)
+	default:
+		writeJSONError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h apiHandler) scoreboard(w http.ResponseWriter, r *http.Request) {
+	var cs []*Channel
+	if name := r.URL.Query().Get("channel"); name != "" {
+		c, found := h.find(name)
+		if !found {
+			http.Error(w, "no such channel: "+name, http.StatusNotFound)
+			return
+		}
+		cs = append(cs, c)
+	} else {
+		cs = h.s.channelList()
+	}
+
+	ecs := make([]exportChannel, 0, len(cs))
+	for _, c := range cs {
+		c.mu.RLock()
+		eus := c.exportUsers()
+		c.mu.RUnlock()
+		// retired users are hidden, like in the stats
+		ec := exportChannel{Name: c.Name, Users: make([]exportUser, 0, len(eus))}
+		for _, eu := range eus {
+			if !eu.Retired {
+				ec.Users = append(ec.Users, eu)
+			}
+		}
+		ecs = append(ecs, ec)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := scoreboardTmpl.Execute(w, ecs); err != nil {
+		_log.Error().Err(err).Str("func", "scoreboard").Send()
+	}
+}
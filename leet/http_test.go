@@ -0,0 +1,153 @@
+package leet
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	c.get("alice").setScore(42)
+	c.get("bob").setScore(7)
+	c.get("bob").lock()
+	c.get("dave").setRetired(true)
+	for day := 1; day <= 3; day++ {
+		c.addHistory(RoundRecord{
+			Date:     time.Date(2023, 1, day, 13, 38, 0, 0, time.UTC),
+			Nicks:    []string{"alice"},
+			TaxIndex: -2,
+			Deltas:   []Delta{{Nick: "alice", Reason: reasonRank, Points: 1}},
+		})
+	}
+	sd.get("#other").get("carol").setScore(1)
+
+	mux := http.NewServeMux()
+	apiHandler{s: sd}.register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getJSON(t *testing.T, srv *httptest.Server, path string, status int, v any) {
+	t.Helper()
+	res, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		t.Fatalf("Expected status %d for %s, got %d", status, path, res.StatusCode)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIChannels(t *testing.T) {
+	srv := getTestServer(t)
+	var acs []apiChannel
+	getJSON(t, srv, "/api/channels", http.StatusOK, &acs)
+	expected := []apiChannel{
+		{Name: testChannel, Users: 3, Winners: 1, Rounds: 3},
+		{Name: "#other", Users: 1},
+	}
+	if len(acs) != len(expected) {
+		t.Fatalf("Expected %d channels, got %d: %+v", len(expected), len(acs), acs)
+	}
+	for idx := range expected {
+		if acs[idx] != expected[idx] {
+			t.Errorf("Expected %+v, got %+v", expected[idx], acs[idx])
+		}
+	}
+}
+
+func TestAPIUsers(t *testing.T) {
+	srv := getTestServer(t)
+
+	var eus []exportUser
+	getJSON(t, srv, "/api/channels/"+url.PathEscape(testChannel)+"/users", http.StatusOK, &eus)
+	if len(eus) != 3 || eus[0].Nick != "alice" || eus[0].Points != 42 {
+		t.Fatalf("Unexpected users: %+v", eus)
+	}
+	if eus[1].Nick != "bob" || eus[1].WinnerRank != 1 {
+		t.Errorf("Expected bob as winner #1, got %+v", eus[1])
+	}
+
+	// without the leading "#"
+	getJSON(t, srv, "/api/channels/other/users", http.StatusOK, &eus)
+	if len(eus) != 1 || eus[0].Nick != "carol" {
+		t.Errorf("Unexpected users: %+v", eus)
+	}
+
+	getJSON(t, srv, "/api/channels/nope/users", http.StatusNotFound, nil)
+	getJSON(t, srv, "/api/channels/other/nope", http.StatusNotFound, nil)
+	getJSON(t, srv, "/api/channels/other", http.StatusNotFound, nil)
+}
+
+func TestAPIRounds(t *testing.T) {
+	srv := getTestServer(t)
+
+	var ers []exportRound
+	getJSON(t, srv, "/api/channels/blackhole/rounds", http.StatusOK, &ers)
+	if len(ers) != 3 {
+		t.Fatalf("Expected 3 rounds, got %d", len(ers))
+	}
+
+	getJSON(t, srv, "/api/channels/blackhole/rounds?limit=2", http.StatusOK, &ers)
+	if len(ers) != 2 || ers[1].Date.Day() != 3 {
+		t.Fatalf("Expected the latest 2 rounds, got %+v", ers)
+	}
+	if len(ers[1].Users) != 1 || ers[1].Users[0].Total != 1 {
+		t.Errorf("Unexpected round: %+v", ers[1])
+	}
+
+	getJSON(t, srv, "/api/channels/blackhole/rounds?limit=x", http.StatusBadRequest, nil)
+}
+
+func TestAPIMethod(t *testing.T) {
+	srv := getTestServer(t)
+	res, err := http.Post(srv.URL+"/api/channels", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, res.StatusCode)
+	}
+}
+
+func TestScoreboard(t *testing.T) {
+	srv := getTestServer(t)
+	res, err := http.Get(srv.URL + "/scoreboard?channel=blackhole")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(body)
+
+	for _, s := range []string{"<h2>#blackhole</h2>", "<td>alice</td><td>42</td>", "<td>#1</td>"} {
+		if !strings.Contains(page, s) {
+			t.Errorf("Expected %q in scoreboard:\n%s", s, page)
+		}
+	}
+	for _, s := range []string{"dave", "#other"} {
+		if strings.Contains(page, s) {
+			t.Errorf("Did not expect %q in scoreboard:\n%s", s, page)
+		}
+	}
+}
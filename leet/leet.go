@@ -11,6 +11,9 @@ import (
 	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
 
+	"github.com/oddlid/dvdgbot/metrics"
+	"github.com/oddlid/dvdgbot/plugin"
+	"github.com/oddlid/dvdgbot/reload"
 	"github.com/oddlid/dvdgbot/util"
 )
 
@@ -20,7 +23,8 @@ const (
 	defaultMinute    = 37                               // Override with env var LEETBOT_MINUTE
 	scoreFile        = "/tmp/leetbot_scores.json"       // Override with env var LEETBOT_SCOREFILE
 	bonusConfigsFile = "/tmp/leetbot_bonusconfigs.json" // Override with env var LEETBOT_BONUSCONFIGFILE
-	plugin           = "LeetBot"                        // Just used for log output
+	logName          = "LeetBot"                        // Just used for log output
+	PluginName       = "leet"                           // Name in the plugin registry and config
 )
 
 var (
@@ -32,10 +36,11 @@ var (
 	_scoreData       *ScoreData
 	_bot             *bot.Bot
 	_bonusConfigs    BonusConfigs
-	_log             = log.With().Str("plugin", plugin).Logger()
+	_log             = log.With().Str("plugin", logName).Logger()
 	_ntpServer       string
 	_ntpOffset       time.Duration
 	_cron            *cron.Cron
+	_plugin          *plugin.Plugin // nil in tests, where all channels are active
 )
 
 // SetParentBot sets the internal global reference to an instance of "github.com/go-chat-bot/bot".
@@ -45,6 +50,12 @@ func SetParentBot(b *bot.Bot) {
 	_bot = b
 }
 
+// active returns true if the plugin is active in channel, so scheduled messages are only
+// posted where its commands can be used
+func active(channel string) bool {
+	return _plugin == nil || _plugin.Active(channel)
+}
+
 func msgChan(channel, msg string) error {
 	_log.Debug().
 		Str("func", "msgChan").
@@ -89,6 +100,13 @@ func inStrSlice(slc []string, val string) (int, bool) {
 	return -1, false
 }
 
+func max0(val int) int {
+	if val < 0 {
+		return 0
+	}
+	return val
+}
+
 func getLongDate(t time.Time) string {
 	// use 0's to get subsecond value padded,
 	// use 9's to get trailing 0's removed.
@@ -100,10 +118,26 @@ func getLongDate(t time.Time) string {
 	return t.Format("2006-01-02 15:04:05.000000000")
 }
 
+func sameDay(t1, t2 time.Time) bool {
+	y1, m1, d1 := t1.Date()
+	y2, m2, d2 := t2.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
 func getShortTime(t time.Time) string {
 	return t.Format("15:04:05.000000000")
 }
 
+// getTimeFrame returns a TimeFrame for the configured target time
+func getTimeFrame() TimeFrame {
+	return TimeFrame{
+		hour:         _hour,
+		minute:       _minute,
+		windowBefore: time.Minute,
+		windowAfter:  time.Minute,
+	}
+}
+
 func timeFrame(t time.Time) TimeCode {
 	th := t.Hour()
 	if th < _hour {
@@ -153,28 +187,46 @@ func checkArgs(cmd *bot.Cmd) (bool, string) {
 	alen := len(cmd.Args)
 	if alen == 1 && cmd.Args[0] == "stats" {
 		if _scoreData.calcInProgress {
-			return false, "Stats are calculating. Try again in a couple of minutes."
-		}
-		return false, _scoreData.stats(cmd.Channel)
-	} else if alen == 1 && cmd.Args[0] == "reload" {
-		// TODO: Handle load errors and give feedback for BC as well
-
-		if err := _bonusConfigs.loadFile(_bonusConfigFile); err != nil {
-			llog.Error().
-				Err(err).
-				Msg("Error loading Bonus Configs from file")
+			return false, _scoreData.get(cmd.Channel).msg("stats_calculating", nil)
 		}
-		if !_scoreData.saveInProgress {
-			_, err := _scoreData.loadFile(_scoreFile)
-			if err != nil {
+		c := _scoreData.get(cmd.Channel)
+		if c.Render == renderPrivate {
+			msg := verboseRenderer{}.renderStats(c, _scoreData.statsResult(c))
+			if err := msgChan(cmd.User.Nick, strings.TrimRight(msg, "\n")); err != nil {
 				llog.Error().Err(err).Send()
 				return false, err.Error()
 			}
-			return false, "Score data reloaded from file"
+			return false, c.msg("stats_private", tdata{"Nick": cmd.User.Nick})
+		}
+		return false, _scoreData.stats(cmd.Channel)
+	} else if alen == 1 && cmd.Args[0] == "reload" {
+		if err := Reload(); err != nil {
+			llog.Error().Err(err).Send()
+			return false, _scoreData.get(cmd.Channel).msg("reload_failed", err.Error())
+		}
+		return false, _scoreData.get(cmd.Channel).msg("reloaded", nil)
+	} else if alen >= 1 && cmd.Args[0] == "bracket" {
+		msg := _scoreData.get(cmd.Channel).bracket(cmd.User.Nick, isAdmin(cmd.User), cmd.Args[1:])
+		if alen > 1 {
+			_scoreData.scheduleSave(_scoreFile, time.Minute) // registration changes must survive a restart
+		}
+		return false, msg
+	} else if alen >= 1 && cmd.Args[0] == "team" {
+		msg := _scoreData.get(cmd.Channel).team(cmd.User.Nick, cmd.Args[1:])
+		if alen > 1 {
+			_scoreData.scheduleSave(_scoreFile, time.Minute)
 		}
-		return false, "A scheduled save is in progress. Will not reload right now."
+		return false, msg
+	} else if alen == 1 && cmd.Args[0] == "global" {
+		return false, _scoreData.globalStats(_scoreData.get(cmd.Channel))
+	} else if alen >= 1 && cmd.Args[0] == "verify" {
+		date := ""
+		if alen > 1 {
+			date = cmd.Args[1]
+		}
+		return false, _scoreData.get(cmd.Channel).verifyRound(date)
 	} else if alen >= 1 {
-		return false, fmt.Sprintf("Unrecognized argument: %q. Usage: !1337 [stats|reload]", cmd.Args[0])
+		return false, _scoreData.get(cmd.Channel).msg("usage", cmd.Args[0])
 	}
 	return true, ""
 }
@@ -196,28 +248,40 @@ func leet(cmd *bot.Cmd) (string, error) {
 
 	// don't give a fuck outside accepted time frame
 	inTimeFrame, tf := withinTimeFrame(t)
+	metrics.LeetEntry(tf.String())
 	if !inTimeFrame {
 		return "", nil
 	}
+	metrics.LeetEntryOffset(t.Sub(time.Date(t.Year(), t.Month(), t.Day(), _hour, _minute, 0, 0, t.Location())))
 
 	// has the user already reached the target point sum and should not contend?
 	c := _scoreData.get(cmd.Channel)
 	u := c.get(cmd.User.Nick)
+
+	// is the game off today?
+	if name, off := c.dayOff(t); off {
+		return c.msg("day_off", tdata{"Nick": u.Nick, "Name": name}), nil
+	}
+
 	if u.isLocked() {
 		tx := timexDiff(_scoreData.BotStart, u.getLastEntry())
-		return fmt.Sprintf(
-			"%s: You're locked, as you're #%d, reaching %d points @ %s after %s :)",
-			u.Nick,
-			c.getWinnerRank(u.Nick),
-			u.getScore(),
-			getLongDate(u.getLastEntry()),
-			tx.String(),
-		), nil
+		return c.msg("locked", tdata{
+			"Nick":     u.Nick,
+			"Rank":     c.getWinnerRank(u.Nick),
+			"Points":   u.getScore(),
+			"Date":     getLongDate(u.getLastEntry()),
+			"Duration": tx.String(),
+		}), nil
 	}
 
 	// is the user spamming?
 	if u.hasTried() {
-		return fmt.Sprintf("%s: Stop spamming!", u.Nick), nil
+		return c.msg("spam", tdata{"Nick": u.Nick}), nil
+	}
+
+	// has the user already played in another channel today?
+	if other := _scoreData.playedElsewhere(c.Name, u.Nick, t); other != "" {
+		return c.msg("played_elsewhere", tdata{"Nick": u.Nick, "Channel": other}), nil
 	}
 
 	// this call also saves the users last entry time, which is important later
@@ -249,7 +313,7 @@ func leet(cmd *bot.Cmd) (string, error) {
 	}
 
 	// bogus
-	return "", fmt.Errorf("%s: Reached beyond logic", plugin)
+	return "", fmt.Errorf("%s: Reached beyond logic", logName)
 }
 
 // getTargetScore should be used only _after_ pickupEnv, as it will modify
@@ -335,8 +399,10 @@ func scheduleNtpCheck(hour, minute int, server string) bool {
 		func() {
 			llog.Info().Msg("Running NTP query...")
 			offset, err := getNtpOffset(server)
+			_ntpStatus.set(err)
 			if err != nil {
 				_ntpOffset = 0 // reset, so we don't use offset that might be way off since last sync
+				metrics.SetNtpOffset(0)
 				llog.Error().Err(err).Send()
 				return
 			}
@@ -344,13 +410,94 @@ func scheduleNtpCheck(hour, minute int, server string) bool {
 				Dur("ntpOffset", offset).
 				Msg("Updating NTP offset")
 			_ntpOffset = offset
-			// notify all channels
-			msg := fmt.Sprintf("NTP offset from %q: %+v", server, _ntpOffset)
-			for channel := range _scoreData.Channels {
-				if err := msgChan(channel, msg); err != nil {
-					llog.Error().Err(err).Msgf("Failed to send message to channel %q", channel)
+			metrics.SetNtpOffset(offset)
+			publish(Event{
+				Type:    EventNtpOffset,
+				Label:   server,
+				Offset:  offset,
+				Message: newMessage("ntp_offset", ntpData(server, offset)).Error(),
+			})
+		},
+	)
+	if err != nil {
+		llog.Error().Err(err).Send()
+		return false
+	}
+	llog.Info().
+		Int("entryID", int(id)).
+		Msg("Cronjob successfully setup, starting cron")
+
+	_cron.Start()
+
+	return true
+}
+
+// scheduleCommitments sets up a cronjob that seeds the next round for all channels with
+// CommitReveal set, and publishes the commitment for the seed before the round starts
+func scheduleCommitments(hour, minute int) bool {
+	llog := _log.With().
+		Str("func", "scheduleCommitments").
+		Int("hour", hour).
+		Int("minute", minute).
+		Logger()
+
+	if _cron == nil {
+		_cron = cron.New()
+	}
+
+	id, err := _cron.AddFunc(
+		fmt.Sprintf("%d %d * * *", minute, hour),
+		func() {
+			committed := false
+			for _, c := range _scoreData.channelList() {
+				if !c.CommitReveal || !active(c.Name) {
+					continue
+				}
+				committed = true
+				if err := msgChan(c.Name, c.msg("commitment", c.commitRound())); err != nil {
+					llog.Error().Err(err).Msgf("Failed to send message to channel %q", c.Name)
 				}
 			}
+			if committed {
+				_scoreData.scheduleSave(_scoreFile, time.Minute) // the seeds must survive a restart before the round
+			}
+		},
+	)
+	if err != nil {
+		llog.Error().Err(err).Send()
+		return false
+	}
+	llog.Info().
+		Int("entryID", int(id)).
+		Msg("Cronjob successfully setup, starting cron")
+
+	_cron.Start()
+
+	return true
+}
+
+// scheduleMaintenance sets up a daily cronjob for decaying points and retiring inactive users,
+// so that it happens even in channels where nobody plays anymore
+func scheduleMaintenance() bool {
+	llog := _log.With().Str("func", "scheduleMaintenance").Logger()
+
+	if _cron == nil {
+		_cron = cron.New()
+	}
+
+	id, err := _cron.AddFunc(
+		"5 0 * * *",
+		func() {
+			changed := false
+			now := time.Now()
+			for _, c := range _scoreData.channelList() {
+				if c.maintain(now) {
+					changed = true
+				}
+			}
+			if changed && !_scoreData.saveInProgress {
+				_scoreData.scheduleSave(_scoreFile, time.Minute)
+			}
 		},
 	)
 	if err != nil {
@@ -376,9 +523,49 @@ func pickupEnv() {
 
 func init() {
 	pickupEnv()
+	plugin.Register(PluginName, setupPlugin)
+}
+
+// setupPlugin is the factory for the plugin, run by the bot if enabled
+func setupPlugin(p *plugin.Plugin, h plugin.Host) error {
+	s, _ := h.Settings.(Settings)
+	_plugin = p
+	SetParentBot(h.Bot)
+	start(s)
+	p.RegisterCommand(
+		"1337",
+		"Register 1337 event, print stats, manage tournament and teams, or verify the tax lottery for a round",
+		"[stats|global|reload|bracket [join|leave|start|reset]|team [join <name>|leave]|verify [YYYY-MM-DD]]",
+		leet,
+	)
+	return nil
+}
+
+// start sets up the game with the given settings, overriding the env vars, loads the score
+// and bonus config files, and schedules the daily jobs
+func start(s Settings) {
+	if s.Hour != nil {
+		_hour = *s.Hour
+	}
+	if s.Minute != nil {
+		_minute = *s.Minute
+	}
+	if s.ScoreFile != "" {
+		_scoreFile = s.ScoreFile
+	}
+	if s.BonusConfigFile != "" {
+		_bonusConfigFile = s.BonusConfigFile
+	}
+	if s.NtpServer != "" {
+		_ntpServer = s.NtpServer
+	}
+	_settingsMu.Lock()
+	_channelSettings = s.Channels
+	_admins = s.Admins
+	_settingsMu.Unlock()
 
 	var err error
-	llog := _log.With().Str("func", "init").Logger()
+	llog := _log.With().Str("func", "start").Logger()
 
 	_scoreData, err = newScoreData().loadFile(_scoreFile)
 	if err != nil {
@@ -386,6 +573,7 @@ func init() {
 			Err(err).
 			Msg("Error loading scoredata from file")
 	}
+	_scoreData.applySettings(s.Channels)
 
 	if err = _bonusConfigs.loadFile(_bonusConfigFile); err != nil {
 		llog.Error().
@@ -415,10 +603,22 @@ func init() {
 		llog.Info().Msg("No NTP server set")
 	}
 
-	bot.RegisterCommand(
-		"1337",
-		"Register 1337 event, or print stats",
-		"[stats|reload]",
-		leet,
-	)
+	// Publish commitments well before the time window opens
+	h, m := getCronTime(_hour, _minute, -5*time.Minute)
+	if !scheduleCommitments(h, m) {
+		llog.Error().Msg("Error scheduling tax lottery commitments")
+	}
+
+	if !scheduleMaintenance() {
+		llog.Error().Msg("Error scheduling daily maintenance")
+	}
+
+	if !scheduleReminders() {
+		llog.Error().Msg("Error scheduling reminders")
+	}
+
+	// post results and notices to IRC
+	Subscribe(ircHandler)
+
+	reload.Register(PluginName, Reload, _scoreFile, _bonusConfigFile)
 }
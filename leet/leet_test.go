@@ -91,15 +91,16 @@ func TestInspection(_ *testing.T) {
 	}
 
 	for i := 0; i < 100; i++ {
-		nickIdx, tax := c.randomInspect()
-		if nickIdx < 0 {
+		c.seedRound() // the same seed gives the same result
+		in := c.inspect(time.Now())
+		if in.index < 0 {
 			continue
 		}
-		nick := c.tmpNicks[nickIdx]
+		nick := c.tmpNicks[in.index]
 		c.get(nick).
 			l.Info().
 			Int("iteration", i).
-			Int("tax", tax).
+			Int("tax", in.tax).
 			Msg("Selected for inspection")
 	}
 }
@@ -112,16 +113,21 @@ func TestInspectLoner(t *testing.T) {
 	nick := "Oddlid"
 	c.clearNicksForRound() // forgetting this made tests fail when running with ./...
 	c.addNickForRound(nick)
+	shouldInspect := func() bool {
+		c.seedRound()
+		doInspect, _ := c.shouldInspect(c.getRand(), time.Now().Weekday())
+		return doInspect
+	}
 
 	c.setInspectAlways(true)
-	if !c.shouldInspect() {
+	if !shouldInspect() {
 		t.Errorf("Set to always inspect, but shouldInspect() returned false anyhow")
 	}
 
 	c.setInspectAlways(false)
 	c.setTaxLoners(false)
 	for i := 0; i < 10; i++ {
-		if c.shouldInspect() {
+		if shouldInspect() {
 			t.Errorf("Set to not inspect loners, but did so anyway. len(c.tmpNicks) = %d", len(c.tmpNicks))
 		}
 	}
@@ -130,7 +136,7 @@ func TestInspectLoner(t *testing.T) {
 	llog := c.get(nick).l
 	for i := 0; i < 10; i++ {
 		llog.Info().
-			Bool("shouldInspect", c.shouldInspect()).
+			Bool("shouldInspect", shouldInspect()).
 			Msg("Inspect?")
 	}
 }
@@ -140,16 +146,35 @@ func TestTaxFail(t *testing.T) {
 	sd := getData()
 	c := sd.get(testChannel)
 	c.InspectionTax = 50.14 // % of total points for the user with the least points in the current round
-	c.PostTaxFail = true
+	c.setInspectAlways(true)
 	for k := range c.Users {
 		c.addNickForRound(k) // adds to c.tmpNicks
 	}
+	c.get(c.tmpNicks[0]).setScore(0) // no tax with someone below 1 point
 
-	if c.Name != testChannel {
-		t.Errorf("Expected channel name %q, got %q", testChannel, c.Name)
+	if rr := c.computeRound(time.Now()); rr.NoTax != "" {
+		t.Errorf("Expected no reason when not posting it, got %q", rr.NoTax)
 	}
 
-	c.randomInspect()
+	c.PostTaxFail = true
+	var posted []string
+	unsub := Subscribe(func(e Event) {
+		if e.Type == EventNoTax && e.Channel == c.Name {
+			posted = append(posted, e.Message)
+		}
+	})
+	defer unsub()
+	rr := c.computeRound(time.Now())
+	if rr.NoTax == "" {
+		t.Fatal("Expected a reason for no tax")
+	}
+	if len(posted) != 0 {
+		t.Errorf("Expected nothing posted before the round is applied, got %q", posted)
+	}
+	c.applyRound(rr)
+	if len(posted) != 1 || posted[0] != rr.NoTax {
+		t.Errorf("Expected %q posted once, got %q", rr.NoTax, posted)
+	}
 }
 
 func TestStats(_ *testing.T) {
@@ -454,7 +479,8 @@ func TestRaceToFinish(t *testing.T) {
 	}
 
 	// do tax
-	idx, tax := c.randomInspect() // most times we get -1 here and skip the rest
+	in := c.inspect(time.Now()) // most times we get -1 here and skip the rest
+	idx, tax := in.index, in.tax
 	if idx > -1 {
 		nick := c.tmpNicks[idx]
 		user := c.get(nick)
@@ -1037,3 +1063,61 @@ func BenchmarkHit1337(b *testing.B) {
 		}
 	}
 }
+
+func TestMaintain(t *testing.T) {
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	c.RetireAfter = 30
+	c.TaxPolicies = []TaxPolicyConfig{
+		{Type: tpInactivity, Days: 7, Percent: 10},
+	}
+	now := time.Now()
+
+	active := c.get("active")
+	active.setScore(100)
+	active.setLastEntry(now)
+
+	idle := c.get("idle")
+	idle.setScore(100)
+	idle.setLastEntry(now.AddDate(0, 0, -10))
+
+	gone := c.get("gone")
+	gone.setScore(100)
+	gone.setLastEntry(now.AddDate(0, 0, -31))
+
+	if !c.maintain(now) {
+		t.Error("Expected maintain to report changes")
+	}
+	if active.getScore() != 100 || active.isRetired() {
+		t.Errorf("Expected active user to be untouched, got %d points, retired: %t", active.getScore(), active.isRetired())
+	}
+	if idle.getScore() != 90 || idle.isRetired() {
+		t.Errorf("Expected idle user to decay to 90 and not retire, got %d points, retired: %t", idle.getScore(), idle.isRetired())
+	}
+	if !gone.isRetired() {
+		t.Error("Expected user inactive for 31 days to be retired")
+	}
+
+	// decay should only happen once per day, and retired users should be left alone
+	c.maintain(now.Add(time.Minute))
+	if idle.getScore() != 90 {
+		t.Errorf("Expected no more decay the same day, got %d points", idle.getScore())
+	}
+	if gone.getScore() != 90 {
+		t.Errorf("Expected retired user to keep 90 points, got %d", gone.getScore())
+	}
+
+	if strings.Contains(sd.stats(testChannel), "gone") {
+		t.Error("Expected retired user to be hidden from stats")
+	}
+
+	// an entry should bring the user back
+	_, msg := sd.tryScore(c, gone, now)
+	if gone.isRetired() {
+		t.Error("Expected retired user to be restored on entry")
+	}
+	if !strings.Contains(msg, "Welcome back!") {
+		t.Errorf("Expected welcome back message, got: %q", msg)
+	}
+	c.clearNicksForRound()
+}
@@ -0,0 +1,174 @@
+package leet
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// defaultLang is used for any message not found in the channel's language or messages file
+const defaultLang = "en"
+
+//go:embed lang/*.json
+var langFS embed.FS
+
+// Messages maps a message key to the text/template source for the message
+type Messages map[string]string
+
+// tdata is the data given to message templates
+type tdata map[string]any
+
+// _languages holds the translations shipped with the bot, keyed by language code
+var _languages = mustLoadLanguages()
+
+// _defaultTemplates are the messages in the default language, for when there's no channel
+var _defaultTemplates = mustBuildTemplates(defaultLang)
+
+var templateFuncs = template.FuncMap{
+	"pad": func(str string, width int) string {
+		return fmt.Sprintf("%-*s", width, str)
+	},
+}
+
+func mustLoadLanguages() map[string]Messages {
+	entries, err := langFS.ReadDir("lang")
+	if err != nil {
+		panic(err)
+	}
+	langs := make(map[string]Messages, len(entries))
+	for _, entry := range entries {
+		data, err := langFS.ReadFile(path.Join("lang", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var msgs Messages
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			panic(fmt.Errorf("%s: %w", entry.Name(), err))
+		}
+		langs[strings.TrimSuffix(entry.Name(), ".json")] = msgs
+	}
+	return langs
+}
+
+func mustBuildTemplates(lang string) *template.Template {
+	tmpl, err := buildTemplates(lang, nil)
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+func loadMessagesFile(filename string) (Messages, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var msgs Messages
+	if err := json.NewDecoder(file).Decode(&msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// buildTemplates parses the default messages, overridden by the given language, and then by overrides
+func buildTemplates(lang string, overrides Messages) (*template.Template, error) {
+	if lang != "" {
+		if _, found := _languages[lang]; !found {
+			return nil, fmt.Errorf("unknown language: %q", lang)
+		}
+	}
+	tmpl := template.New("").Funcs(templateFuncs)
+	for _, msgs := range []Messages{_languages[defaultLang], _languages[lang], overrides} {
+		for key, src := range msgs {
+			if _, err := tmpl.New(key).Parse(src); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tmpl, nil
+}
+
+// getTemplates returns the message templates for the channel, built on first use from
+// Language and MessagesFile. Falls back to the defaults if any of them fails.
+func (c *Channel) getTemplates() *template.Template {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.templates != nil {
+		return c.templates
+	}
+
+	llog := c.l.With().Str("func", "getTemplates").Logger()
+	var overrides Messages
+	if c.MessagesFile != "" {
+		var err error
+		if overrides, err = loadMessagesFile(c.MessagesFile); err != nil {
+			llog.Error().Err(err).Str("filename", c.MessagesFile).Msg("Error loading messages")
+		}
+	}
+	tmpl, err := buildTemplates(c.Language, overrides)
+	if err != nil {
+		llog.Error().Err(err).Msg("Error parsing messages, using defaults")
+		tmpl, _ = buildTemplates(defaultLang, nil)
+	}
+	c.templates = tmpl
+	return tmpl
+}
+
+// writeMsg writes the message for key, in the channel's language, to w
+func (c *Channel) writeMsg(w io.Writer, key string, data any) {
+	if err := c.getTemplates().ExecuteTemplate(w, key, data); err != nil {
+		c.l.Error().
+			Err(err).
+			Str("func", "writeMsg").
+			Str("key", key).
+			Send()
+	}
+}
+
+// msg returns the message for key, in the channel's language
+func (c *Channel) msg(key string, data any) string {
+	var sb strings.Builder
+	c.writeMsg(&sb, key, data)
+	return sb.String()
+}
+
+// message is the key and data for a message, for code that has no channel to render it with,
+// like the tournament. It's also an error, for errors to show to the user.
+type message struct {
+	data any
+	key  string
+}
+
+func newMessage(key string, data any) message {
+	return message{key: key, data: data}
+}
+
+// Error returns the message in the default language
+func (m message) Error() string {
+	var sb strings.Builder
+	if err := _defaultTemplates.ExecuteTemplate(&sb, m.key, m.data); err != nil {
+		return m.key
+	}
+	return sb.String()
+}
+
+// render returns m in the channel's language
+func (c *Channel) render(m message) string {
+	return c.msg(m.key, m.data)
+}
+
+// errMsg returns err in the channel's language, if it's a message
+func (c *Channel) errMsg(err error) string {
+	var m message
+	if errors.As(err, &m) {
+		return c.render(m)
+	}
+	return err.Error()
+}
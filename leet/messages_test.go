@@ -0,0 +1,113 @@
+package leet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+)
+
+func TestLanguages(t *testing.T) {
+	for _, lang := range []string{"en", "no", "sv"} {
+		msgs, found := _languages[lang]
+		if !found {
+			t.Errorf("Missing language: %q", lang)
+			continue
+		}
+		for key := range msgs {
+			if _, found := _languages[defaultLang][key]; !found {
+				t.Errorf("Language %q has unknown key: %q", lang, key)
+			}
+		}
+		for key := range _languages[defaultLang] {
+			if _, found := msgs[key]; !found {
+				t.Errorf("Language %q is missing key: %q", lang, key)
+			}
+		}
+		if _, err := buildTemplates(lang, nil); err != nil {
+			t.Errorf("Error parsing language %q: %v", lang, err)
+		}
+	}
+	if _, err := buildTemplates("xx", nil); err == nil {
+		t.Errorf("Expected error for unknown language")
+	}
+}
+
+func TestChannelMessages(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	if msg := c.msg("spam", tdata{"Nick": "Oddlid"}); msg != "Oddlid: Stop spamming!" {
+		t.Errorf("Unexpected default message: %q", msg)
+	}
+
+	c = newScoreData().get(testChannel)
+	c.Language = "sv"
+	if msg := c.msg("spam", tdata{"Nick": "Oddlid"}); msg != "Oddlid: Sluta spamma!" {
+		t.Errorf("Unexpected Swedish message: %q", msg)
+	}
+
+	filename := filepath.Join(t.TempDir(), "messages.json")
+	data := `{"spam": "{{.Nick}}: Ro deg ned!"}`
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c = newScoreData().get(testChannel)
+	c.Language = "no"
+	c.MessagesFile = filename
+	if msg := c.msg("spam", tdata{"Nick": "Oddlid"}); msg != "Oddlid: Ro deg ned!" {
+		t.Errorf("Unexpected overridden message: %q", msg)
+	}
+	if msg := c.msg("welcome_back", nil); msg != " Velkommen tilbake!" {
+		t.Errorf("Expected Norwegian for messages not overridden, got: %q", msg)
+	}
+}
+
+func TestStatsLanguage(t *testing.T) {
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	c.Language = "no"
+	c.get("Oddlid").setScore(42)
+
+	stats := sd.stats(testChannel)
+	if !strings.HasPrefix(stats, "Statistikk siden") || !strings.Contains(stats, "Oddlid : 0042") {
+		t.Errorf("Unexpected stats: %s", stats)
+	}
+}
+
+func TestReplyLanguage(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	c.Language = "sv"
+
+	if msg := c.team("alice", []string{"leave"}); msg != "alice: inte medlem i något lag" {
+		t.Errorf("Unexpected Swedish error reply: %q", msg)
+	}
+	if msg := c.team("alice", []string{"join", "floor1"}); msg != `alice gick med i laget "floor1"` {
+		t.Errorf("Unexpected Swedish reply: %q", msg)
+	}
+	if err := c.leaveTeam("bob"); err == nil || err.Error() != "not a member of any team" {
+		t.Errorf("Expected error to render in English outside a channel, got: %v", err)
+	}
+}
+
+func TestCommandLanguage(t *testing.T) {
+	sd := _scoreData
+	t.Cleanup(func() { _scoreData = sd })
+	_scoreData = newScoreData()
+	c := _scoreData.get(testChannel)
+	c.Language = "sv"
+
+	cmd := &bot.Cmd{Channel: testChannel, User: &bot.User{Nick: "alice"}, Args: []string{"nope"}}
+	if _, msg := checkArgs(cmd); !strings.HasPrefix(msg, `Okänt argument: "nope". Användning: !1337 [stats|`) {
+		t.Errorf("Unexpected Swedish usage: %q", msg)
+	}
+	_scoreData.calcInProgress = true
+	cmd.Args = []string{"stats"}
+	if _, msg := checkArgs(cmd); msg != "Statistiken beräknas. Försök igen om ett par minuter." {
+		t.Errorf("Unexpected Swedish reply while calculating: %q", msg)
+	}
+	if msg := c.msg("ntp_offset", ntpData("pool.ntp.org", 3*time.Millisecond)); msg != `NTP-avvikelse från "pool.ntp.org": 3ms` {
+		t.Errorf("Unexpected Swedish NTP offset: %q", msg)
+	}
+}
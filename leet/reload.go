@@ -0,0 +1,147 @@
+package leet
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// validate checks the settings for the channel, including the files it refers to
+func (c *Channel) validate() error {
+	if c.InspectionTax < 0 || c.InspectionTax > 100 {
+		return fmt.Errorf("inspection_tax must be between 0 and 100, got %v", c.InspectionTax)
+	}
+	if c.OvershootTax < 0 {
+		return fmt.Errorf("overshoot_tax can not be negative, got %d", c.OvershootTax)
+	}
+	if c.RetireAfter < 0 {
+		return fmt.Errorf("retire_after_days can not be negative, got %d", c.RetireAfter)
+	}
+	switch c.Render {
+	case "", renderVerbose, renderCompact, renderPrivate:
+	default:
+		return fmt.Errorf("unknown render: %q", c.Render)
+	}
+	for _, tpc := range c.TaxPolicies {
+		if _, err := tpc.policy(); err != nil {
+			return err
+		}
+	}
+	if c.Reminders != nil {
+		for _, date := range c.Reminders.QuietDates {
+			if _, err := time.Parse(dateFmt, date); err != nil {
+				return fmt.Errorf("reminders: %w", err)
+			}
+		}
+	}
+	if c.CalendarFile != "" {
+		if _, err := loadCalendarFile(c.CalendarFile); err != nil {
+			return fmt.Errorf("calendar_file: %w", err)
+		}
+	}
+	var overrides Messages
+	if c.MessagesFile != "" {
+		var err error
+		if overrides, err = loadMessagesFile(c.MessagesFile); err != nil {
+			return fmt.Errorf("messages_file: %w", err)
+		}
+	}
+	if _, err := buildTemplates(c.Language, overrides); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate checks the settings for all channels
+func (s *ScoreData) validate() error {
+	for name, c := range s.Channels {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("channel %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (bcs BonusConfigs) validate() error {
+	for idx, bc := range bcs {
+		if bc.SubString == "" {
+			return fmt.Errorf("bonus config #%d: empty SubString", idx+1)
+		}
+	}
+	return nil
+}
+
+// keepRound moves the state for the round in progress from old to c, so a reload during
+// the time window doesn't lose who has entered. The scheduled calculation of the round
+// looks the channel up by name, so it scores c, not old.
+func (c *Channel) keepRound(old *Channel) {
+	old.mu.RLock()
+	defer old.mu.RUnlock()
+	c.tmpNicks = old.tmpNicks
+	c.seeded = old.seeded
+	c.seed = old.seed
+	c.seedFunc = old.seedFunc
+	c.commitment = old.commitment
+	c.Committed = old.Committed
+	for nick, u := range c.Users {
+		if ou, found := old.Users[nick]; found {
+			u.try(ou.hasTried())
+		}
+	}
+}
+
+// reload reads filename into a new instance, and replaces the channels and settings in s
+// only if all of it is valid. As with "!1337 reload", scores from the file replace the ones
+// in memory, but the round in progress is kept.
+func (s *ScoreData) reload(filename string) error {
+	fresh, err := newScoreData().loadFile(filename)
+	if err != nil {
+		return err
+	}
+	fresh.applySettings(channelSettings())
+	if err := fresh.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, c := range fresh.Channels {
+		c.Name = name
+		c.l = s.l.With().Str("channel", name).Logger()
+		if old, found := s.Channels[name]; found {
+			c.keepRound(old)
+		}
+	}
+	s.Channels = fresh.Channels
+	s.Aliases = fresh.Aliases
+	s.OneChannelPerDay = fresh.OneChannelPerDay
+	return nil
+}
+
+// Reload reads the bonus configs and score file again, and replaces the current ones only
+// if both are valid. A missing bonus config file is not an error, as the game works without.
+func Reload() error {
+	if _scoreData.saveInProgress {
+		return errors.New("a scheduled save is in progress, will not reload right now")
+	}
+
+	var bcs BonusConfigs
+	err := bcs.loadFile(_bonusConfigFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		bcs = _bonusConfigs
+	} else if err != nil {
+		return fmt.Errorf("bonus configs: %w", err)
+	}
+	if err := bcs.validate(); err != nil {
+		return fmt.Errorf("bonus configs: %w", err)
+	}
+
+	if err := _scoreData.reload(_scoreFile); err != nil {
+		return fmt.Errorf("scores: %w", err)
+	}
+	_bonusConfigs = bcs
+
+	scheduleReminders()
+	return nil
+}
@@ -0,0 +1,144 @@
+package leet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+)
+
+func TestReloadScoreData(t *testing.T) {
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	c.get("alice").setScore(42)
+	c.addNickForRound("alice")
+	c.get("alice").try(true)
+
+	filename := filepath.Join(t.TempDir(), "scores.json")
+	if err := sd.saveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// an invalid setting should keep everything as is
+	c.get("alice").setScore(50)
+	c.Render = "fancy"
+	if err := sd.saveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	c.Render = ""
+	c.get("alice").setScore(60)
+	if err := sd.reload(filename); err == nil {
+		t.Fatal("Expected error for invalid render")
+	}
+	if sd.get(testChannel) != c || c.get("alice").getScore() != 60 {
+		t.Errorf("Expected current channel kept after failed reload")
+	}
+
+	// a valid file replaces the scores, but keeps the round in progress
+	c.Render = renderCompact
+	if err := sd.saveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	c.get("alice").setScore(70)
+	if err := sd.reload(filename); err != nil {
+		t.Fatal(err)
+	}
+	nc := sd.get(testChannel)
+	if nc == c {
+		t.Fatal("Expected a new channel after reload")
+	}
+	if nc.Render != renderCompact || nc.get("alice").getScore() != 60 {
+		t.Errorf("Expected settings and scores from file, got render %q and %d points", nc.Render, nc.get("alice").getScore())
+	}
+	if len(nc.tmpNicks) != 1 || !nc.get("alice").hasTried() {
+		t.Errorf("Expected round in progress to be kept, got %v", nc.tmpNicks)
+	}
+
+	if err := os.WriteFile(filename, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.reload(filename); err == nil || sd.get(testChannel) != nc {
+		t.Errorf("Expected error and current channel kept for broken file, got %v", err)
+	}
+}
+
+func TestReloadBeforeCalc(t *testing.T) {
+	sd, c := getSeededChannel(1337)
+	filename := filepath.Join(t.TempDir(), "scores.json")
+	if err := sd.saveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	scored := make(chan string, 1)
+	unsub := Subscribe(func(e Event) {
+		if e.Type == EventRoundScored && e.Channel == testChannel {
+			scored <- e.Message
+		}
+	})
+	defer unsub()
+
+	if !sd.scheduleCalcScore(c, 50*time.Millisecond) {
+		t.Fatal("Expected calculation to be scheduled")
+	}
+	if err := sd.reload(filename); err != nil {
+		t.Fatal(err)
+	}
+	nc := sd.get(testChannel)
+	if nc == c {
+		t.Fatal("Expected a new channel after reload")
+	}
+
+	select {
+	case <-scored:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the round to be scored")
+	}
+	if len(nc.History) != 1 || nc.hasPendingScores() {
+		t.Errorf("Expected the round scored on the reloaded channel")
+	}
+	if len(c.History) != 0 {
+		t.Errorf("Expected nothing scored on the old channel")
+	}
+	if err := sd.saveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := newScoreData().loadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc := saved.get(testChannel); len(sc.History) != 1 {
+		t.Errorf("Expected the round in the saved data")
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	sd, settings, admins := _scoreData, _channelSettings, _admins
+	t.Cleanup(func() { _scoreData, _channelSettings, _admins = sd, settings, admins })
+	_scoreData = newScoreData()
+
+	lang := "sv"
+	if err := ApplySettings(Settings{
+		Channels: map[string]ChannelSettings{testChannel: {Language: &lang}},
+		Admins:   []string{"alice"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if c := _scoreData.get(testChannel); c.Language != "sv" {
+		t.Errorf("Expected language applied, got %q", c.Language)
+	}
+	if _, found := channelSettings()[testChannel]; !found || !isAdmin(&bot.User{Nick: "alice"}) {
+		t.Errorf("Expected settings and admins kept for later reloads")
+	}
+
+	render := "fancy"
+	if err := ApplySettings(Settings{
+		Channels: map[string]ChannelSettings{testChannel: {Render: &render}},
+	}); err == nil {
+		t.Fatal("Expected error for invalid render")
+	}
+	if c := _scoreData.get(testChannel); c.Render != "" || !isAdmin(&bot.User{Nick: "alice"}) {
+		t.Errorf("Expected current settings kept after invalid config, got render %q", c.Render)
+	}
+}
@@ -0,0 +1,157 @@
+package leet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ReminderConfig is the per channel setup for announcements before and after the time window.
+// Reminders are off for a channel unless this is set.
+type ReminderConfig struct {
+	Before        []int          `json:"before"`         // minutes before target time to post a countdown, e.g. [5, 1]
+	QuietWeekdays []time.Weekday `json:"quiet_weekdays"` // no announcements on these days, 0 = Sunday
+	QuietDates    []string       `json:"quiet_dates"`    // no announcements on these dates, YYYY-MM-DD
+	Teaser        bool           `json:"teaser"`         // post a teaser for the results when the time window closes
+}
+
+// cron entries for reminders, so they can be replaced on reload
+var _reminderIDs []cron.EntryID
+
+// teaserDelay is when, relative to the target time, the time window has closed
+const teaserDelay = 2 * time.Minute
+
+// quiet returns true if there should be no announcements on the day of t
+func (rc *ReminderConfig) quiet(t time.Time) bool {
+	for _, wd := range rc.QuietWeekdays {
+		if t.Weekday() == wd {
+			return true
+		}
+	}
+	_, found := inStrSlice(rc.QuietDates, t.Format("2006-01-02"))
+	return found
+}
+
+// quiet returns true if the channel should have no announcements on the day of t
+func (c *Channel) quiet(t time.Time) bool {
+	if _, off := c.dayOff(t); off {
+		return true
+	}
+	return c.Reminders.quiet(t)
+}
+
+func (rc *ReminderConfig) hasBefore(minutes int) bool {
+	for _, m := range rc.Before {
+		if m == minutes {
+			return true
+		}
+	}
+	return false
+}
+
+// reminderOffsets returns all distinct countdown offsets configured in any channel, in minutes
+func (s *ScoreData) reminderOffsets() []int {
+	seen := make(map[int]bool)
+	offsets := make([]int, 0)
+	for _, c := range s.channelList() {
+		if c.Reminders == nil {
+			continue
+		}
+		for _, m := range c.Reminders.Before {
+			if m < 1 || seen[m] {
+				continue
+			}
+			seen[m] = true
+			offsets = append(offsets, m)
+		}
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// remind posts the countdown to all channels that want it for the given number of minutes
+func (s *ScoreData) remind(now time.Time, minutes int) {
+	data := tdata{"Minutes": minutes, "Time": fmt.Sprintf("%02d:%02d", _hour, _minute)}
+	for _, c := range s.channelList() {
+		if c.Reminders == nil || !c.Reminders.hasBefore(minutes) || c.quiet(now) || !active(c.Name) {
+			continue
+		}
+		if err := msgChan(c.Name, c.msg("reminder", data)); err != nil {
+			c.l.Error().Err(err).Str("func", "remind").Send()
+		}
+	}
+}
+
+// teaser returns the teaser for the results, or false if the round has been scored already,
+// as the calculation may run before the teaser when someone got on time early
+func (c *Channel) teaser(now time.Time) (string, bool) {
+	c.mu.RLock()
+	num := len(c.tmpNicks)
+	scored := len(c.History) > 0 && sameDay(c.History[len(c.History)-1].Date, now)
+	c.mu.RUnlock()
+	if scored {
+		return "", false
+	}
+	return c.msg("tease", num), true
+}
+
+// tease posts a teaser for the results to all channels that want it, when the time window has closed
+func (s *ScoreData) tease(now time.Time) {
+	for _, c := range s.channelList() {
+		if c.Reminders == nil || !c.Reminders.Teaser || c.quiet(now) || !active(c.Name) {
+			continue
+		}
+		msg, ok := c.teaser(now)
+		if !ok {
+			continue
+		}
+		if err := msgChan(c.Name, msg); err != nil {
+			c.l.Error().Err(err).Str("func", "tease").Send()
+		}
+	}
+}
+
+// scheduleReminders sets up cronjobs for the reminders configured in all channels,
+// replacing any reminders scheduled before
+func scheduleReminders() bool {
+	llog := _log.With().Str("func", "scheduleReminders").Logger()
+
+	if _cron == nil {
+		_cron = cron.New()
+	}
+	for _, id := range _reminderIDs {
+		_cron.Remove(id)
+	}
+	_reminderIDs = nil
+
+	add := func(adjust time.Duration, job func()) bool {
+		h, m := getCronTime(_hour, _minute, adjust)
+		id, err := _cron.AddFunc(fmt.Sprintf("%d %d * * *", m, h), job)
+		if err != nil {
+			llog.Error().Err(err).Send()
+			return false
+		}
+		_reminderIDs = append(_reminderIDs, id)
+		return true
+	}
+
+	for _, minutes := range _scoreData.reminderOffsets() {
+		minutes := minutes
+		if !add(-time.Duration(minutes)*time.Minute, func() { _scoreData.remind(time.Now(), minutes) }) {
+			return false
+		}
+	}
+	if !add(teaserDelay, func() { _scoreData.tease(time.Now()) }) {
+		return false
+	}
+
+	llog.Info().
+		Int("entries", len(_reminderIDs)).
+		Msg("Reminders scheduled, starting cron")
+
+	_cron.Start()
+
+	return true
+}
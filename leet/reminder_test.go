@@ -0,0 +1,69 @@
+package leet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReminderQuiet(t *testing.T) {
+	rc := &ReminderConfig{
+		QuietWeekdays: []time.Weekday{time.Saturday, time.Sunday},
+		QuietDates:    []string{"2023-12-24"},
+	}
+	tests := []struct {
+		date  string
+		quiet bool
+	}{
+		{"2023-12-22", false}, // Friday
+		{"2023-12-23", true},  // Saturday
+		{"2023-12-24", true},  // Sunday, and listed
+		{"2023-12-25", false}, // Monday
+	}
+	for _, tt := range tests {
+		ts, err := time.Parse("2006-01-02", tt.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rc.quiet(ts); got != tt.quiet {
+			t.Errorf("Expected quiet to be %t for %s, but got %t", tt.quiet, tt.date, got)
+		}
+	}
+}
+
+func TestReminderOffsets(t *testing.T) {
+	sd := newScoreData()
+	sd.get("#one").Reminders = &ReminderConfig{Before: []int{5, 1}}
+	sd.get("#two").Reminders = &ReminderConfig{Before: []int{10, 5, 0}}
+	sd.get("#three")
+
+	offsets := sd.reminderOffsets()
+	expected := []int{1, 5, 10}
+	if len(offsets) != len(expected) {
+		t.Fatalf("Expected offsets %v, but got %v", expected, offsets)
+	}
+	for i := range expected {
+		if offsets[i] != expected[i] {
+			t.Errorf("Expected offsets %v, but got %v", expected, offsets)
+		}
+	}
+}
+
+func TestTeaser(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	now := time.Now()
+
+	if msg, ok := c.teaser(now); !ok || msg != "Time's up! Nobody made it on time today :(" {
+		t.Errorf("Unexpected teaser for an empty round: %q", msg)
+	}
+	c.addNickForRound("alice")
+	c.addNickForRound("bob")
+	if msg, ok := c.teaser(now); !ok || msg != "Time's up! 2 on time today. Results coming up..." {
+		t.Errorf("Unexpected teaser: %q", msg)
+	}
+
+	// the results are out before the teaser
+	c.applyRound(c.computeRound(now))
+	if msg, ok := c.teaser(now); ok {
+		t.Errorf("Expected no teaser after the round was scored, got %q", msg)
+	}
+}
@@ -0,0 +1,291 @@
+package leet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Ways to render results and stats for a channel
+const (
+	renderVerbose = "verbose" // one padded line per user, the default
+	renderCompact = "compact" // everything on a single line
+	renderPrivate = "private" // compact results in the channel, and full stats in private to whoever asked
+)
+
+// StatsLine is the standing for a user
+type StatsLine struct {
+	LastEntry  time.Time
+	BestEntry  time.Time
+	Nick       string
+	Greeting   string
+	Points     int
+	BonusTimes int
+	BonusTotal int
+	TaxTimes   int
+	TaxTotal   int
+	Misses     int
+	Winner     int // winner rank, starting at 1, or 0 if not a winner
+}
+
+// StatsResult is the standing for all users and teams in a channel
+type StatsResult struct {
+	Since time.Time
+	Lines []StatsLine
+	Teams []TeamLine
+}
+
+type renderer interface {
+	renderRound(c *Channel, rr *RoundResult) string
+	renderStats(c *Channel, sr *StatsResult) string
+}
+
+type (
+	verboseRenderer struct{}
+	compactRenderer struct{}
+)
+
+func greetingFor(total int) string {
+	if has, bc := _bonusConfigs.hasValue(total); has {
+		return bc.Greeting
+	}
+	return ""
+}
+
+// renderer returns the renderer configured for the channel
+func (c *Channel) renderer() renderer {
+	switch c.Render {
+	case renderCompact, renderPrivate:
+		return compactRenderer{}
+	default:
+		return verboseRenderer{}
+	}
+}
+
+func (verboseRenderer) renderRound(c *Channel, rr *RoundResult) string {
+	var sb strings.Builder
+	maxNickLen := c.Users.longestNickLen()
+
+	c.writeMsg(&sb, "results_header", tdata{"Date": rr.Date.Format("2006-01-02")})
+	fmt.Fprintf(&sb, "\n")
+	if rr.DoublePoints != "" {
+		c.writeMsg(&sb, "double_points", tdata{"Name": rr.DoublePoints})
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	for _, rl := range rr.Lines {
+		writePad(&sb, maxNickLen, rl.Nick)
+		fmt.Fprintf(&sb, ": %04d", rl.Total)
+		if rl.Policy != "" {
+			c.writeMsg(&sb, "policy_"+rl.Policy, rl.PolicyPoints)
+		} else {
+			writeRank(c, &sb, rl.Rank)
+			writeOvershootTax(c, &sb, rl.OvershootTax)
+			writeTax(c, &sb, rl.Tax)
+		}
+		writeWinner(c, &sb, rl.Winner)
+		if rl.Greeting != "" {
+			fmt.Fprintf(&sb, " - %s", rl.Greeting)
+		}
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	if len(rr.Teams) > 0 {
+		c.writeMsg(&sb, "teams_header", nil)
+		fmt.Fprintf(&sb, "\n")
+	}
+	for _, tl := range rr.Teams {
+		fmt.Fprintf(&sb, "%s: %04d", tl.Name, tl.Points)
+		writeRank(c, &sb, tl.Rank)
+		writeOvershootTax(c, &sb, tl.OvershootTax)
+		writeWinner(c, &sb, tl.Winner)
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	for _, msg := range rr.Bracket {
+		c.writeMsg(&sb, "bracket_line", msg)
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	if rr.Reveal {
+		c.writeMsg(&sb, "seed_reveal", tdata{"Seed": rr.Seed, "Commitment": rr.Commitment})
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	return sb.String()
+}
+
+func (verboseRenderer) renderStats(c *Channel, sr *StatsResult) string {
+	var sb strings.Builder
+	width := c.Users.longestNickLen()
+
+	c.writeMsg(&sb, "stats_header", tdata{"Since": sr.Since.Format(time.RFC3339)})
+	fmt.Fprintf(&sb, "\n")
+	for _, sl := range sr.Lines {
+		c.writeMsg(&sb, "stats_line", tdata{
+			"Nick":       sl.Nick,
+			"Width":      width,
+			"Points":     sl.Points,
+			"LastEntry":  getLongDate(sl.LastEntry),
+			"BestEntry":  getLongDate(sl.BestEntry),
+			"BonusTimes": sl.BonusTimes,
+			"BonusTotal": sl.BonusTotal,
+			"TaxTimes":   sl.TaxTimes,
+			"TaxTotal":   sl.TaxTotal,
+			"Misses":     sl.Misses,
+		})
+		writeWinner(c, &sb, sl.Winner)
+		if sl.Greeting != "" {
+			fmt.Fprintf(&sb, " - %s", sl.Greeting)
+		}
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	sb.WriteString(renderTeamStats(c, sr.Teams))
+
+	return sb.String()
+}
+
+// renderTeamStats returns the team leaderboard, or an empty string if there are no teams
+func renderTeamStats(c *Channel, tls []TeamLine) string {
+	if len(tls) == 0 {
+		return ""
+	}
+	maxLen := 0
+	for _, tl := range tls {
+		if len(tl.Name) > maxLen {
+			maxLen = len(tl.Name)
+		}
+	}
+	var sb strings.Builder
+	c.writeMsg(&sb, "teams_header", nil)
+	fmt.Fprintf(&sb, "\n")
+	for _, tl := range tls {
+		writePad(&sb, maxLen, tl.Name)
+		fmt.Fprintf(&sb, ": %04d (%s)", tl.Points, strings.Join(tl.Members, ", "))
+		writeWinner(c, &sb, tl.Winner)
+		fmt.Fprintf(&sb, "\n")
+	}
+	return sb.String()
+}
+
+func (compactRenderer) renderRound(c *Channel, rr *RoundResult) string {
+	// sum up all lines for each user, keeping the order the users first appear in
+	type sum struct {
+		delta  int
+		total  int
+		winner int
+	}
+	var nicks []string
+	sums := make(map[string]*sum)
+	for _, rl := range rr.Lines {
+		s, found := sums[rl.Nick]
+		if !found {
+			s = &sum{}
+			sums[rl.Nick] = s
+			nicks = append(nicks, rl.Nick)
+		}
+		s.delta += rl.delta()
+		s.total = rl.Total
+		s.winner = rl.Winner
+	}
+
+	parts := make([]string, 0, 4)
+	header := c.msg("results_header", tdata{"Date": rr.Date.Format("2006-01-02")})
+	if rr.DoublePoints != "" {
+		header += " " + c.msg("double_points", tdata{"Name": rr.DoublePoints})
+	}
+	users := make([]string, 0, len(nicks))
+	for idx, nick := range nicks {
+		s := sums[nick]
+		users = append(users, c.msg("compact_line", tdata{
+			"Pos":    idx + 1,
+			"Nick":   nick,
+			"Delta":  s.delta,
+			"Total":  s.total,
+			"Winner": s.winner,
+		}))
+	}
+	parts = append(parts, header+" "+strings.Join(users, ", "))
+
+	if len(rr.Teams) > 0 {
+		teams := make([]string, 0, len(rr.Teams))
+		for idx, tl := range rr.Teams {
+			teams = append(teams, c.msg("compact_line", tdata{
+				"Pos":    idx + 1,
+				"Nick":   tl.Name,
+				"Delta":  tl.Rank - tl.OvershootTax,
+				"Total":  tl.Points,
+				"Winner": tl.Winner,
+			}))
+		}
+		parts = append(parts, c.msg("compact_teams", strings.Join(teams, ", ")))
+	}
+	if len(rr.Bracket) > 0 {
+		parts = append(parts, c.msg("compact_bracket", strings.Join(rr.Bracket, ", ")))
+	}
+	if rr.Reveal {
+		parts = append(parts, c.msg("compact_seed", tdata{"Seed": rr.Seed, "Commitment": rr.Commitment}))
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+func (compactRenderer) renderStats(c *Channel, sr *StatsResult) string {
+	users := make([]string, 0, len(sr.Lines))
+	for idx, sl := range sr.Lines {
+		users = append(users, c.msg("compact_stats_line", tdata{
+			"Pos":    idx + 1,
+			"Nick":   sl.Nick,
+			"Total":  sl.Points,
+			"Winner": sl.Winner,
+		}))
+	}
+	msg := c.msg("stats_header", tdata{"Since": sr.Since.Format(time.RFC3339)}) + " " + strings.Join(users, ", ")
+	if len(sr.Teams) > 0 {
+		teams := make([]string, 0, len(sr.Teams))
+		for idx, tl := range sr.Teams {
+			teams = append(teams, c.msg("compact_stats_line", tdata{
+				"Pos":    idx + 1,
+				"Nick":   tl.Name,
+				"Total":  tl.Points,
+				"Winner": tl.Winner,
+			}))
+		}
+		msg += " | " + c.msg("compact_teams", strings.Join(teams, ", "))
+	}
+	return msg
+}
+
+func writeRank(c *Channel, w io.Writer, val int) {
+	if val == 0 {
+		return
+	}
+	c.writeMsg(w, "rank", val)
+}
+
+func writeOvershootTax(c *Channel, w io.Writer, val int) {
+	if val == 0 {
+		return
+	}
+	c.writeMsg(w, "overshoot_tax", val)
+}
+
+func writeTax(c *Channel, w io.Writer, val int) {
+	if val == -1 {
+		return
+	}
+	if val == 0 {
+		c.writeMsg(w, "tax_slap", nil)
+		return
+	}
+	c.writeMsg(w, "tax", val)
+}
+
+func writeWinner(c *Channel, w io.Writer, rank int) {
+	if rank == 0 {
+		return
+	}
+	c.writeMsg(w, "winner", rank)
+}
@@ -0,0 +1,110 @@
+package leet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTestRoundResult() *RoundResult {
+	return &RoundResult{
+		Date: time.Date(2023, 1, 13, 13, 38, 0, 0, time.UTC),
+		Lines: []RoundLine{
+			{Nick: "alice", Total: 1337, Rank: 3, Tax: -1, Winner: 1},
+			{Nick: "bob", Total: 40, Rank: 2, Tax: 5},
+			{Nick: "carol", Total: 11, Rank: 1, Tax: -1},
+			{Nick: "carol", Total: 13, Tax: -1, Policy: tpRedistribute, PolicyPoints: 2},
+		},
+		Teams: []TeamLine{
+			{Name: "floor1", Points: 100, Rank: 4},
+		},
+	}
+}
+
+func TestRenderRoundCompact(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	msg := compactRenderer{}.renderRound(c, getTestRoundResult())
+	expected := "Results for 2023-01-13: 1. alice +3 = 1337 - Winner #1!, 2. bob -3 = 40, 3. carol +3 = 13 | Teams: 1. floor1 +4 = 100"
+	if msg != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, msg)
+	}
+}
+
+func TestRenderRoundVerbose(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	for _, nick := range []string{"alice", "bob", "carol"} {
+		c.get(nick)
+	}
+	// retired users are not shown, so they don't count for the padding
+	c.get("retired_long_nick").setRetired(true)
+	msg := verboseRenderer{}.renderRound(c, getTestRoundResult())
+	lines := strings.Split(strings.TrimRight(msg, "\n"), "\n")
+	expected := []string{
+		"Results for 2023-01-13:",
+		"alice : 1337 [Rank: +03] - Winner #1!",
+		"bob   : 0040 [Rank: +02] [Tax: -5]",
+		"carol : 0011 [Rank: +01]",
+		"carol : 0013 [Redistribution: +2]",
+		"Teams:",
+		"floor1: 0100 [Rank: +04]",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(expected), len(lines), msg)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected line %d to be %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestRenderStatsCompact(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	sr := &StatsResult{
+		Since: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Lines: []StatsLine{
+			{Nick: "alice", Points: 1337, Winner: 1},
+			{Nick: "bob", Points: 42},
+		},
+	}
+	msg := compactRenderer{}.renderStats(c, sr)
+	expected := "Stats since 2023-01-01T00:00:00Z: 1. alice 1337 - Winner #1!, 2. bob 42"
+	if msg != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, msg)
+	}
+}
+
+func TestChannelRenderer(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	if _, ok := c.renderer().(verboseRenderer); !ok {
+		t.Errorf("Expected verbose renderer by default")
+	}
+	for _, mode := range []string{renderCompact, renderPrivate} {
+		c.Render = mode
+		if _, ok := c.renderer().(compactRenderer); !ok {
+			t.Errorf("Expected compact renderer for %q", mode)
+		}
+	}
+}
+
+func TestRenderRoundLanguage(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	c.Language = "no"
+	rr := getTestRoundResult()
+	rr.Bracket = []string{"alice slo bob"}
+	rr.Reveal = true
+	rr.Seed = 1337
+	msg := compactRenderer{}.renderRound(c, rr)
+	for _, part := range []string{" | Lag: 1. floor1", " | Turnering: alice slo bob", " | Frø: 1337 (ingen forpliktelse) (!1337 verify)"} {
+		if !strings.Contains(msg, part) {
+			t.Errorf("Expected %q in: %s", part, msg)
+		}
+	}
+	msg = verboseRenderer{}.renderRound(c, rr)
+	if !strings.Contains(msg, "[Omfordeling: +2]") {
+		t.Errorf("Expected Norwegian policy line in: %s", msg)
+	}
+	if !strings.Contains(msg, "\nLag:\n") || !strings.Contains(msg, "Frø for dagens skattelotteri: 1337") {
+		t.Errorf("Unexpected verbose round: %s", msg)
+	}
+}
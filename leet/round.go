@@ -0,0 +1,255 @@
+package leet
+
+import (
+	"sort"
+	"time"
+)
+
+// Reasons for a change in points in a round
+const (
+	reasonRank      = "rank"
+	reasonOvershoot = "overshoot_tax"
+	reasonTax       = "tax"
+	reasonPolicy    = "policy"
+)
+
+// Delta is a change in points for a user in a round, and the reason for it
+type Delta struct {
+	Nick   string `json:"nick"`
+	Reason string `json:"reason"`           // rank, overshoot_tax, tax or policy
+	Policy string `json:"policy,omitempty"` // the tax policy type, if Reason is policy
+	Points int    `json:"points"`           // negative for taxes
+}
+
+// RoundLine is the outcome for a user in one step of the round, for rendering
+type RoundLine struct {
+	Nick         string
+	Greeting     string // bonus greeting for the total, if any
+	Policy       string // type of the tax policy, if the line is from one
+	Total        int    // points after this line was applied
+	Rank         int    // rank points
+	OvershootTax int
+	Tax          int // inspection tax, -1 if not inspected
+	PolicyPoints int
+	Winner       int // winner rank, starting at 1, or 0 if not a winner
+}
+
+// TeamLine is the outcome for a team in the round, or its standing in the stats
+type TeamLine struct {
+	Name         string
+	Members      []string
+	Points       int
+	Rank         int // rank points in the round
+	OvershootTax int
+	Winner       int // winner rank, starting at 1, or 0 if not a winner
+}
+
+// RoundResult is everything that happens in a round. It's created by computeRound without
+// changing any scores, and then applied to the channel by applyRound.
+type RoundResult struct {
+	Date         time.Time
+	record       RoundRecord // for the history, with what's needed to replay the inspection
+	DoublePoints string      // name of the day, if rank points were doubled
+	Commitment   string
+	NoTax        string   // why there was no tax inspection, if the channel posts that
+	Deltas       []Delta  // all changes in points, in the order they happen
+	Locks        []string // users reaching the target score in the round
+	Lines        []RoundLine
+	Teams        []TeamLine
+	Bracket      []string // filled in by applyRound, as matches are decided then
+	Seed         int64
+	Reveal       bool // if the seed should be revealed
+}
+
+// delta returns the net change in points for the line
+func (rl RoundLine) delta() int {
+	return rl.Rank - rl.OvershootTax - max0(rl.Tax) + rl.PolicyPoints
+}
+
+// computeRound calculates the outcome of the current round: rank points, inspection and
+// overshoot tax, tax policies, winners and teams. Nothing is changed or posted, and the
+// inspection is drawn from the seed for the round, so it gives the same result every time.
+func (c *Channel) computeRound(now time.Time) *RoundResult {
+	scoreMap := c.getScoresForRound()
+	rr := &RoundResult{Date: now}
+	target := getTargetScore()
+	tr := &taxRound{now: now, target: target} // keeps track of taxes collected and points so far in the round
+
+	// rank points are doubled on double points days
+	if name, double := c.doublePoints(now); double {
+		for nick := range scoreMap {
+			scoreMap[nick] *= 2
+		}
+		rr.DoublePoints = name
+	}
+
+	in := c.inspect(now) // in.index is the index of the taxed nick in c.tmpNicks, if any
+	rr.record = c.roundRecord(in)
+	if c.PostTaxFail {
+		rr.NoTax = in.noTax
+	}
+
+	c.mu.RLock()
+	nicks := append([]string(nil), c.tmpNicks...)
+	c.mu.RUnlock()
+
+	change := func(nick, reason string, points int) {
+		if points == 0 {
+			return
+		}
+		rr.Deltas = append(rr.Deltas, Delta{Nick: nick, Reason: reason, Points: points})
+		tr.setScore(nick, tr.score(c.get(nick))+points)
+		if points < 0 {
+			tr.collected += -points
+		}
+	}
+
+	locked := make(map[string]bool)
+	checkLock := func(nick string) {
+		if tr.score(c.get(nick)) == target && !c.get(nick).isLocked() && !locked[nick] {
+			locked[nick] = true
+			rr.Locks = append(rr.Locks, nick)
+		}
+	}
+
+	// rank points go first, as the overshoot tax is calculated from the total including them
+	for _, nick := range nicks {
+		change(nick, reasonRank, scoreMap[nick])
+	}
+
+	// first we loop through the participants of this round that got on time and got points for that
+	for idx, nick := range nicks { // looping on tmpNicks will keep the sort order for most points
+		taxDeduction := -1
+		if idx == in.index {
+			taxDeduction = in.tax
+		}
+		overshootTax := c.getOverShootTaxFor(target, tr.score(c.get(nick)))
+		change(nick, reasonOvershoot, -overshootTax)
+		change(nick, reasonTax, -max0(taxDeduction))
+		checkLock(nick)
+		rr.Lines = append(rr.Lines, RoundLine{
+			Nick:         nick,
+			Total:        tr.score(c.get(nick)),
+			Rank:         scoreMap[nick],
+			OvershootTax: overshootTax,
+			Tax:          taxDeduction,
+		})
+	}
+
+	// a user can be past the target but not in tmpNicks if the user missed the time and got -1 for that,
+	// but also got a bonus that made the total of those positive, and pushed the user to or over the limit
+	c.mu.RLock()
+	var others []*User
+	for nick, u := range c.Users {
+		if _, found := scoreMap[nick]; found {
+			continue
+		}
+		// a user can be marked as a winner from earlier rounds. We don't want to see those here.
+		if u.getScore() >= target && u.lastTSInCurrentRound(now) {
+			others = append(others, u)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].Nick < others[j].Nick
+	})
+	for _, u := range others {
+		overshootTax := c.getOverShootTaxFor(target, tr.score(u))
+		change(u.Nick, reasonOvershoot, -overshootTax)
+		checkLock(u.Nick)
+		rr.Lines = append(rr.Lines, RoundLine{
+			Nick:         u.Nick,
+			Total:        tr.score(u),
+			OvershootTax: overshootTax,
+			Tax:          -1,
+		})
+	}
+
+	// run the extra tax policies configured for the channel, after the regular taxes are collected
+	c.runTaxPolicies(tr, "")
+	for _, te := range tr.entries {
+		rr.Deltas = append(rr.Deltas, Delta{Nick: te.nick, Reason: reasonPolicy, Policy: te.policy, Points: te.points})
+		checkLock(te.nick)
+		rr.Lines = append(rr.Lines, RoundLine{
+			Nick:         te.nick,
+			Total:        tr.score(c.get(te.nick)),
+			Tax:          -1,
+			Policy:       te.policy,
+			PolicyPoints: te.points,
+		})
+	}
+
+	// Winners are ranked by when they got there, so the new ones come after the ones we
+	// already have, and the earliest entry in this round first
+	winners := len(c.Users.filterByLocked(true))
+	lockRanks := make(map[string]int, len(rr.Locks))
+	sort.SliceStable(rr.Locks, func(i, j int) bool {
+		return c.get(rr.Locks[i]).getLastEntry().Before(c.get(rr.Locks[j]).getLastEntry())
+	})
+	for idx, nick := range rr.Locks {
+		lockRanks[nick] = winners + idx + 1
+	}
+	for i := range rr.Lines {
+		rl := &rr.Lines[i]
+		if rl.Total == target {
+			rl.Winner = lockRanks[rl.Nick]
+		}
+		rl.Greeting = greetingFor(rl.Total)
+	}
+
+	// teams get the sum of their members' rank points
+	rr.Teams = c.computeTeams(scoreMap)
+
+	if c.CommitReveal {
+		rr.Reveal = true
+		rr.Seed = rr.record.Seed
+		rr.Commitment = rr.record.Commitment
+	}
+
+	return rr
+}
+
+// applyRound applies the result from computeRound to the channel, records it in the history,
+// and gets ready for the next round
+func (c *Channel) applyRound(rr *RoundResult) {
+	if rr.NoTax != "" {
+		publish(Event{Type: EventNoTax, Channel: c.Name, Message: rr.NoTax})
+	}
+	for _, d := range rr.Deltas {
+		u := c.get(d.Nick)
+		switch d.Reason {
+		case reasonRank:
+			u.addScore(d.Points)
+		case reasonOvershoot:
+			u.addScore(d.Points)
+			u.addTax(-d.Points)
+			c.publishTax(d.Nick, "Overshoot tax", -d.Points)
+		case reasonTax:
+			u.addScore(d.Points)
+			u.addTax(-d.Points)
+			c.publishTax(d.Nick, "Tax", -d.Points)
+		case reasonPolicy:
+			c.applyTaxEntry(u, d.Policy, d.Points, rr.Date)
+		}
+	}
+	for _, nick := range rr.Locks {
+		u := c.get(nick)
+		u.lock()
+		c.publishLocked(u)
+	}
+
+	c.applyTeams(rr.Teams, rr.Date)
+
+	// decide today's matches in the tournament, if one is running
+	if c.Tournament != nil {
+		for _, m := range c.Tournament.playRound(c.tournamentEntry(rr.Date)) {
+			rr.Bracket = append(rr.Bracket, c.render(m))
+		}
+	}
+
+	rec := rr.record
+	rec.Deltas = rr.Deltas
+	c.addHistory(rec) // record before tmpNicks is cleared, so the round can be replayed later
+
+	c.clearNicksForRound() // clean up, before next round
+}
@@ -0,0 +1,99 @@
+package leet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeRoundIsPure(t *testing.T) {
+	_, c := getSeededChannel(1337)
+	before := make(map[string]int)
+	for nick, u := range c.Users {
+		before[nick] = u.getScore()
+	}
+
+	rr := c.computeRound(time.Now())
+	// computing it again gives the same result
+	if again := c.computeRound(time.Now()); !reflect.DeepEqual(rr.Deltas, again.Deltas) {
+		t.Errorf("Expected the same deltas, got %+v and %+v", rr.Deltas, again.Deltas)
+	}
+
+	for nick, u := range c.Users {
+		if u.getScore() != before[nick] {
+			t.Errorf("Expected %s to still have %d points, got %d", nick, before[nick], u.getScore())
+		}
+	}
+	if len(c.History) != 0 {
+		t.Errorf("Expected no history before the round is applied")
+	}
+
+	// rank points for all 4, and a tax for one of them, since we always inspect
+	reasons := make(map[string]int)
+	for _, d := range rr.Deltas {
+		reasons[d.Reason]++
+	}
+	if reasons[reasonRank] != 4 || reasons[reasonTax] != 1 {
+		t.Errorf("Unexpected deltas: %+v", rr.Deltas)
+	}
+}
+
+func TestApplyRound(t *testing.T) {
+	_, c := getSeededChannel(1337)
+	before := make(map[string]int)
+	for nick, u := range c.Users {
+		before[nick] = u.getScore()
+	}
+
+	rr := c.computeRound(time.Now())
+	c.applyRound(rr)
+
+	sums := make(map[string]int)
+	for _, d := range rr.Deltas {
+		sums[d.Nick] += d.Points
+	}
+	for nick, u := range c.Users {
+		if u.getScore() != before[nick]+sums[nick] {
+			t.Errorf("Expected %s to have %d points, got %d", nick, before[nick]+sums[nick], u.getScore())
+		}
+	}
+	for _, rl := range rr.Lines {
+		if rl.Total != c.get(rl.Nick).getScore() {
+			t.Errorf("Expected line total for %s to match the score: %d != %d", rl.Nick, rl.Total, c.get(rl.Nick).getScore())
+		}
+	}
+
+	if len(c.History) != 1 || len(c.History[0].Deltas) != len(rr.Deltas) {
+		t.Fatalf("Expected the deltas to be recorded in the history")
+	}
+	if !c.History[0].verify() {
+		t.Errorf("Recorded round does not verify")
+	}
+	if c.hasPendingScores() {
+		t.Errorf("Expected the round to be cleared")
+	}
+}
+
+func TestComputeRoundWinner(t *testing.T) {
+	_, c := getSeededChannel(1337)
+	c.setInspectAlways(false)
+	c.InspectionTax = 0
+	// Oddlid is first of 4, and gets 4 points
+	c.get("Oddlid").setScore(getTargetScore() - 4)
+
+	rr := c.computeRound(time.Now())
+	if len(rr.Locks) != 1 || rr.Locks[0] != "Oddlid" {
+		t.Fatalf("Expected Oddlid to be locked, got %v", rr.Locks)
+	}
+	if rr.Lines[0].Winner != 1 {
+		t.Errorf("Expected Oddlid to be winner #1, got %d", rr.Lines[0].Winner)
+	}
+	if c.get("Oddlid").isLocked() {
+		t.Errorf("Expected Oddlid to not be locked before the round is applied")
+	}
+
+	c.applyRound(rr)
+	if !c.get("Oddlid").isLocked() || c.getWinnerRank("Oddlid") != 0 {
+		t.Errorf("Expected Oddlid to be locked as winner #1")
+	}
+}
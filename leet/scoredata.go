@@ -5,18 +5,28 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
+
+	"github.com/oddlid/dvdgbot/metrics"
+	"github.com/oddlid/dvdgbot/reload"
 )
 
 type ScoreData struct {
-	Channels       map[string]*Channel `json:"channels"`
-	l              zerolog.Logger
-	BotStart       time.Time `json:"botstart"`
-	saveInProgress bool
-	calcInProgress bool
+	Channels         map[string]*Channel `json:"channels"`
+	Aliases          map[string]string   `json:"aliases,omitempty"` // nick -> nick to count as in the global leaderboard
+	l                zerolog.Logger
+	BotStart         time.Time    `json:"botstart"`
+	mu               sync.RWMutex // guards Channels, lastSave and lastSaveErr, for readers outside of the bot, like the HTTP API
+	lastSave         time.Time    // when the latest successful save finished
+	lastSaveErr      error        // error from the latest save, nil if it went well
+	saveInProgress   bool
+	calcInProgress   bool
+	OneChannelPerDay bool `json:"one_channel_per_day"` // if a user can only score in one of the global channels per day
 }
 
 func newScoreData() *ScoreData {
@@ -36,7 +46,18 @@ func (s *ScoreData) load(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(jb, s)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.Unmarshal(jb, s); err != nil {
+		return err
+	}
+	// files from older versions may not have the name in the channel
+	now := time.Now()
+	for name, c := range s.Channels {
+		c.Name = name
+		c.restoreCommitment(now)
+	}
+	return nil
 }
 
 func (s *ScoreData) loadFile(filename string) (*ScoreData, error) {
@@ -56,7 +77,9 @@ func (s *ScoreData) loadFile(filename string) (*ScoreData, error) {
 }
 
 func (s *ScoreData) save(w io.Writer) (int, error) {
+	s.mu.RLock()
 	jb, err := json.MarshalIndent(s, "", "\t")
+	s.mu.RUnlock()
 	if err != nil {
 		return 0, err
 	}
@@ -64,7 +87,13 @@ func (s *ScoreData) save(w io.Writer) (int, error) {
 	return w.Write(jb)
 }
 
-func (s *ScoreData) saveFile(filename string) error {
+func (s *ScoreData) saveFile(filename string) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.Saved(time.Since(start), err)
+		s.setSaveResult(err)
+	}()
+
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -74,6 +103,7 @@ func (s *ScoreData) saveFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	reload.Saved(filename)
 	s.l.Info().
 		Int("bytes", n).
 		Str("filename", filename).
@@ -98,132 +128,9 @@ func (s *ScoreData) scheduleSave(filename string, delay time.Duration) bool {
 }
 
 func (s *ScoreData) calcScore(c *Channel) string {
-	scoreMap := c.getScoresForRound()
-	var sb strings.Builder
-
-	total := func(w io.Writer, val int) {
-		fmt.Fprintf(w, ": %04d", val)
-	}
-
-	rank := func(w io.Writer, val int) {
-		if val == 0 {
-			return
-		}
-		fmt.Fprintf(w, " [Rank: +%02d]", val)
-	}
-
-	otax := func(w io.Writer, val int) {
-		if val == 0 {
-			return
-		}
-		fmt.Fprintf(w, " [Overshoot tax: -%d]", val)
-	}
-
-	tax := func(w io.Writer, val int) {
-		if val == -1 {
-			return
-		}
-		if val == 0 {
-			fmt.Fprintf(w, " [Tax: Slap on the wrist ;)]")
-			return
-		}
-		fmt.Fprintf(w, " [Tax: -%d]", val)
-	}
-
-	greeting := func(w io.Writer, total int) {
-		has, bc := _bonusConfigs.hasValue(total)
-		if !has {
-			return
-		}
-		fmt.Fprintf(w, " - %s", bc.Greeting)
-	}
-
-	winner := func(w io.Writer, nick string) {
-		isWinner := c.get(nick).isLocked()
-		if !isWinner {
-			return
-		}
-		fmt.Fprintf(w, " - Winner #%d!", c.getWinnerRank(nick)+1)
-	}
-
-	maxNickLen := c.Users.longestNickLen()
-
-	genmsg := func(w io.Writer, nick string, tot, rnk, ostax, regtax int) {
-		writePad(w, maxNickLen, nick)
-		total(w, tot)
-		rank(w, rnk)
-		otax(w, ostax)
-		tax(w, regtax)
-		winner(w, nick)
-		greeting(w, tot)
-	}
-
-	// If we are to use *OverShooters(), we need to remember to save other +- points to the users
-	// first, as those funcs uses the users getScore() to determine how much to deduct or if the user should be on the list.
-	// It might be better to just replicate it at the call site (here), if we need more flexibility.
-
-	// generate header
-	fmt.Fprintf(&sb, "Results for %s:\n", time.Now().Format("2006-01-02"))
-
-	// taxNickIndex is the index of the taxed nick in c.tmpNicks
-	taxNickIndex, taxVal := c.randomInspect() // taxNickIndex will be -2 if c.shouldInspect returns false because of weekday != rnd
-	c.mergeScoresForRound(scoreMap)           // this needs to come before getOverShooters()
-	osmap := c.getOverShooters(getTargetScore())
-	// first we loop through the participants of this round that got on time and got points for that
-	for idx, nick := range c.tmpNicks { // looping on tmpNicks will keep the sort order for most points
-		// We need to compare each nick to entries in osmap, since we want to show the overshoot tax _either_ here, or
-		// after this loop, but not both.
-		u := c.get(nick)
-		taxDeduction := -1
-		if idx == taxNickIndex {
-			taxDeduction = taxVal
-		}
-		rankPoints := scoreMap[nick] // this has been applied already, only for display purposes
-		overshootTax := c.getOverShootTaxFor(getTargetScore(), u.getScore())
-		// We now need to update the users points before we can get a greeting or mark as a winner
-		if overshootTax > 0 {
-			u.addScore(-overshootTax) // apply overshoot tax
-			u.addTax(overshootTax)
-		}
-		if taxDeduction > 0 {
-			u.addScore(-taxDeduction) // apply random tax
-			u.addTax(taxDeduction)
-		}
-		// If the user is now at at total that matches target score, it needs to be marked as a winner, before we move on
-		if getTargetScore() == u.getScore() {
-			u.lock()
-		}
-		genmsg(&sb, nick, u.getScore(), rankPoints, overshootTax, taxDeduction)
-		fmt.Fprintf(&sb, "\n")
-	}
-	// a user can be in osmap but not in tmpNicks if the user missed the time and got -1 for that, but also got a bonus
-	// that made the total of those positive, and pushed the user to or over the limit
-	now := time.Now() // cache time since we're comparing in a loop
-	for nick, user := range osmap {
-		_, found := inStrSlice(c.tmpNicks, nick)
-		if found {
-			// If the overshooter is also a round contestant, we already dealt with it in the previous loop
-			continue
-		}
-		// a user can be marked as a winner from earlier rounds. We don't want to see those here.
-		if !user.lastTSInCurrentRound(now) {
-			continue
-		}
-		overshootTax := c.getOverShootTaxFor(getTargetScore(), user.getScore())
-		if overshootTax > 0 {
-			user.addScore(-overshootTax)
-			user.addTax(overshootTax)
-		}
-		if getTargetScore() == user.getScore() {
-			user.lock()
-		}
-		genmsg(&sb, nick, user.getScore(), 0, overshootTax, -1)
-		fmt.Fprintf(&sb, "\n")
-	}
-
-	c.clearNicksForRound() // clean up, before next round
-
-	return sb.String()
+	rr := c.computeRound(time.Now())
+	c.applyRound(rr)
+	return c.renderer().renderRound(c, rr)
 }
 
 func (s *ScoreData) scheduleCalcScore(c *Channel, delay time.Duration) bool {
@@ -231,19 +138,26 @@ func (s *ScoreData) scheduleCalcScore(c *Channel, delay time.Duration) bool {
 		return false
 	}
 	s.calcInProgress = true
+	name := c.Name
 	time.AfterFunc(delay, func() {
-		if err := msgChan(c.Name, strings.TrimRight(s.calcScore(c), "\n")); err != nil {
-			s.l.Error().
-				Err(err).
-				Str("func", "scheduleCalcScore").
-				Send()
-		}
+		// look up the channel when it's time, as a reload in the meantime replaces it
+		c := s.get(name)
+		publish(Event{
+			Type:    EventRoundScored,
+			Channel: c.Name,
+			Message: strings.TrimRight(s.calcScore(c), "\n"),
+		})
 		s.calcInProgress = false
 	})
 	return s.calcInProgress
 }
 
 func (s *ScoreData) get(channel string) *Channel {
+	if c, found := s.lookup(channel); found {
+		return c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	c, found := s.Channels[channel]
 	if !found {
 		c = &Channel{
@@ -259,9 +173,36 @@ func (s *ScoreData) get(channel string) *Channel {
 	return c
 }
 
+// lookup returns the given channel, without creating it if it doesn't exist
+func (s *ScoreData) lookup(channel string) (*Channel, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, found := s.Channels[channel]
+	return c, found
+}
+
+// channelList returns all channels, sorted by name
+func (s *ScoreData) channelList() []*Channel {
+	s.mu.RLock()
+	cs := make([]*Channel, 0, len(s.Channels))
+	for _, c := range s.Channels {
+		cs = append(cs, c)
+	}
+	s.mu.RUnlock()
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Name < cs[j].Name
+	})
+	return cs
+}
+
 func (s *ScoreData) stats(channel string) string {
 	c := s.get(channel)
-	var sb strings.Builder
+	return c.renderer().renderStats(c, s.statsResult(c))
+}
+
+// statsResult returns the current standing for all active users and teams in the channel
+func (s *ScoreData) statsResult(c *Channel) *StatsResult {
+	sr := &StatsResult{Since: s.BotStart}
 
 	// This replaces the old func rank() that used KV/KVList
 	us := c.Users.toSlice().sortByPointsDesc()
@@ -271,52 +212,34 @@ func (s *ScoreData) stats(channel string) string {
 	// of c.getWinnerRank, to speed up things a bit.
 	ws := c.Users.filterByLocked(true).sortByLastEntryAsc()
 
-	greeting := func(w io.Writer, total int) {
-		has, bc := _bonusConfigs.hasValue(total)
-		if !has {
-			return
-		}
-		fmt.Fprintf(w, " - %s", bc.Greeting)
-	}
-
-	winner := func(w io.Writer, user *User) {
-		isWinner := user.isLocked()
-		if !isWinner {
-			return
-		}
-		fmt.Fprintf(w, " - Winner #%d!", ws.getIndex(user.Nick)+1)
-	}
-
-	fstr := getPadStrFmt(
-		c.Users.longestNickLen(),
-		": %04d @ %s Best: %s Bonus: %03dx = %04d Tax: %03dx = -%04d Miss: -%04d",
-	)
-
-	fmt.Fprintf(&sb, "Stats since %s:\n", s.BotStart.Format(time.RFC3339))
-
 	// It should be safe to access fields in user struct directly here without calling the methods
 	// that lock, since we have guards otherwise that should prevent this method to be run in
 	// parallell with anything.
 	for _, u := range us {
-		fmt.Fprintf(
-			&sb,
-			fstr,
-			u.Nick,
-			u.Points,
-			getLongDate(u.getLastEntry()),
-			getLongDate(u.getBestEntry()),
-			u.getBonusTimes(),
-			u.getBonusTotal(),
-			u.getTaxTimes(),
-			u.getTaxTotal(),
-			u.getMissTotal(),
-		)
-		winner(&sb, u)
-		greeting(&sb, u.Points)
-		fmt.Fprintf(&sb, "\n")
+		if u.isRetired() {
+			continue
+		}
+		sl := StatsLine{
+			Nick:       u.Nick,
+			Points:     u.Points,
+			LastEntry:  u.getLastEntry(),
+			BestEntry:  u.getBestEntry(),
+			BonusTimes: u.getBonusTimes(),
+			BonusTotal: u.getBonusTotal(),
+			TaxTimes:   u.getTaxTimes(),
+			TaxTotal:   u.getTaxTotal(),
+			Misses:     u.getMissTotal(),
+			Greeting:   greetingFor(u.Points),
+		}
+		if u.isLocked() {
+			sl.Winner = ws.getIndex(u.Nick) + 1
+		}
+		sr.Lines = append(sr.Lines, sl)
 	}
 
-	return sb.String()
+	sr.Teams = c.teamLines()
+
+	return sr
 }
 
 func (s *ScoreData) tryScore(c *Channel, u *User, t time.Time) (bool, string) {
@@ -333,29 +256,34 @@ func (s *ScoreData) tryScore(c *Channel, u *User, t time.Time) (bool, string) {
 			Str("func", "tryScore").
 			Bool("didScore", didScore).
 			Msg("It should not be possible to reach this branch")
-		return false, fmt.Sprintf("%s: I'm retarded and made a logical error :'(", u.Nick)
+		return false, c.msg("logic_error", u.Nick)
 	}
 
-	missTmpl := fmt.Sprintf("%s Too %s, sucker! %s: %d", ts, "%s", u.Nick, userTotal)
+	// any entry brings a retired user back into the game
+	welcomeBack := ""
+	if u.isRetired() {
+		u.setRetired(false)
+		welcomeBack = c.msg("welcome_back", nil)
+	}
+
+	bonus := ""
 	if bonusPoints > 0 {
 		u.addBonus(bonusPoints)
-		missTmpl += fmt.Sprintf(" (but: %s)", brs)
+		bonus = brs.String()
+		publish(Event{Type: EventBonus, Time: t, Channel: c.Name, Nick: u.Nick, Points: bonusPoints, Message: bonus})
 	}
 
-	if tcEarly == tf {
-		u.addMiss()
-		return true, fmt.Sprintf(missTmpl, "early")
-	} else if tcLate == tf {
+	if tcEarly == tf || tcLate == tf {
 		u.addMiss()
-		return true, fmt.Sprintf(missTmpl, "late")
+		msg := c.msg("miss", tdata{"Time": ts, "Early": tcEarly == tf, "Nick": u.Nick, "Total": userTotal, "Bonus": bonus})
+		publish(Event{Type: EventMiss, Time: t, Channel: c.Name, Nick: u.Nick, Points: points, Message: msg})
+		return true, msg + welcomeBack
 	}
 
 	rank := c.addNickForRound(u.Nick) // how many points is calculated from how many times this is called, later on
 
-	ret := fmt.Sprintf("%s Whoop! %s: #%d", ts, u.Nick, rank)
-	if bonusPoints > 0 {
-		ret = fmt.Sprintf("%s (%s)", ret, brs)
-	}
+	ret := c.msg("entry", tdata{"Time": ts, "Nick": u.Nick, "Rank": rank, "Bonus": bonus})
+	publish(Event{Type: EventEntry, Time: t, Channel: c.Name, Nick: u.Nick, Points: rank, Message: ret})
 
-	return true, ret
+	return true, ret + welcomeBack
 }
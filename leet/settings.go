@@ -0,0 +1,150 @@
+package leet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-chat-bot/bot"
+)
+
+// Settings for the game, as given in the bot's config file. Empty values keep what's set by
+// the env vars, or the defaults.
+type Settings struct {
+	Hour            *int                       `yaml:"hour"`
+	Minute          *int                       `yaml:"minute"`
+	Channels        map[string]ChannelSettings `yaml:"channels"` // key is channel name
+	ScoreFile       string                     `yaml:"score_file"`
+	BonusConfigFile string                     `yaml:"bonus_config_file"`
+	NtpServer       string                     `yaml:"ntp_server"`
+	Admins          []string                   `yaml:"admins"` // may start and reset tournaments, see isAdmin
+}
+
+// ChannelSettings overrides the settings for a channel in the score file. Settings not given
+// here are kept as they are in the score file. The channel is created if not in the score file.
+type ChannelSettings struct {
+	InspectionTax *float64 `yaml:"inspection_tax"`
+	OvershootTax  *int     `yaml:"overshoot_tax"`
+	RetireAfter   *int     `yaml:"retire_after_days"`
+	InspectAlways *bool    `yaml:"inspect_always"`
+	TaxLoners     *bool    `yaml:"tax_loners"`
+	PostTaxFail   *bool    `yaml:"post_tax_fail"`
+	CommitReveal  *bool    `yaml:"commit_reveal"`
+	Global        *bool    `yaml:"global"`
+	Language      *string  `yaml:"language"`
+	Render        *string  `yaml:"render"`
+	CalendarFile  *string  `yaml:"calendar_file"`
+	MessagesFile  *string  `yaml:"messages_file"`
+}
+
+var (
+	// channel settings from the config file, applied each time the score file is loaded
+	_channelSettings map[string]ChannelSettings
+	// admins from the config file
+	_admins []string
+	// guards the above, as they're replaced when the config file is reloaded
+	_settingsMu sync.RWMutex
+)
+
+func channelSettings() map[string]ChannelSettings {
+	_settingsMu.RLock()
+	defer _settingsMu.RUnlock()
+	return _channelSettings
+}
+
+// isAdmin returns true if the user is one of the admins. An admin is given as a nick, or as
+// nick@host to require the host as well, since anyone can take a nick that's not registered.
+func isAdmin(u *bot.User) bool {
+	_settingsMu.RLock()
+	defer _settingsMu.RUnlock()
+	for _, admin := range _admins {
+		nick, host, withHost := strings.Cut(admin, "@")
+		if strings.EqualFold(nick, u.Nick) && (!withHost || strings.EqualFold(host, u.ID)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cs ChannelSettings) apply(c *Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cs.InspectionTax != nil {
+		c.InspectionTax = *cs.InspectionTax
+	}
+	if cs.OvershootTax != nil {
+		c.OvershootTax = *cs.OvershootTax
+	}
+	if cs.RetireAfter != nil {
+		c.RetireAfter = *cs.RetireAfter
+	}
+	if cs.InspectAlways != nil {
+		c.InspectAlways = *cs.InspectAlways
+	}
+	if cs.TaxLoners != nil {
+		c.TaxLoners = *cs.TaxLoners
+	}
+	if cs.PostTaxFail != nil {
+		c.PostTaxFail = *cs.PostTaxFail
+	}
+	if cs.CommitReveal != nil {
+		c.CommitReveal = *cs.CommitReveal
+	}
+	if cs.Global != nil {
+		c.Global = *cs.Global
+	}
+	if cs.Language != nil {
+		c.Language = *cs.Language
+	}
+	if cs.Render != nil {
+		c.Render = *cs.Render
+	}
+	if cs.CalendarFile != nil {
+		c.CalendarFile = *cs.CalendarFile
+	}
+	if cs.MessagesFile != nil {
+		c.MessagesFile = *cs.MessagesFile
+	}
+}
+
+// applySettings overrides the settings for the given channels
+func (s *ScoreData) applySettings(settings map[string]ChannelSettings) {
+	for name, cs := range settings {
+		cs.apply(s.get(name))
+	}
+}
+
+// ApplySettings replaces the channel settings and admins with the ones from a reloaded config
+// file, if they're valid. The time of the game and the files it uses are only read at start.
+func ApplySettings(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	_settingsMu.Lock()
+	_channelSettings = s.Channels
+	_admins = s.Admins
+	_settingsMu.Unlock()
+	_scoreData.applySettings(s.Channels)
+	return nil
+}
+
+// Validate checks the settings, including the files the channels refer to. The score file
+// and bonus config file are not required to exist, as they're created if missing.
+func (s Settings) Validate() error {
+	var errs []error
+	if s.Hour != nil && (*s.Hour < 0 || *s.Hour > 23) {
+		errs = append(errs, fmt.Errorf("hour must be between 0 and 23, got %d", *s.Hour))
+	}
+	if s.Minute != nil && (*s.Minute < 0 || *s.Minute > 59) {
+		errs = append(errs, fmt.Errorf("minute must be between 0 and 59, got %d", *s.Minute))
+	}
+	sd := newScoreData()
+	sd.applySettings(s.Channels)
+	for _, c := range sd.channelList() {
+		if err := c.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("channel %q: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,271 @@
+package leet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Names for the built in tax policies, as used in TaxPolicyConfig.Type
+const (
+	tpWealth       = "wealth"       // progressive tax on the leader
+	tpInactivity   = "inactivity"   // decay points for users not seen in a while
+	tpRedistribute = "redistribute" // share the tax collected in the round between the lowest players
+)
+
+// Labels for the tax events published on the bus. Results in the channels use the
+// policy_<type> messages instead.
+var taxPolicyLabels = map[string]string{
+	tpWealth:       "Wealth tax",
+	tpInactivity:   "Inactivity decay",
+	tpRedistribute: "Redistribution",
+}
+
+// TaxPolicy is a rule for moving points between users after a round.
+// Policies are run after the inspection and overshoot taxes, in the order configured for the channel.
+type TaxPolicy interface {
+	name() string
+	apply(c *Channel, tr *taxRound)
+}
+
+// TaxBracket is a step in the progressive wealth tax.
+// Points above Above, up to the next bracket, are taxed by Percent.
+type TaxBracket struct {
+	Above   int     `json:"above"`
+	Percent float64 `json:"percent"`
+}
+
+// TaxPolicyConfig is how a tax policy is configured for a channel in the JSON file.
+// Which fields are used depends on Type.
+type TaxPolicyConfig struct {
+	Type     string       `json:"type"`               // wealth, inactivity or redistribute
+	Brackets []TaxBracket `json:"brackets,omitempty"` // wealth: tax brackets, in any order
+	Days     int          `json:"days,omitempty"`     // inactivity: days without an entry before points decay
+	Percent  float64      `json:"percent,omitempty"`  // inactivity: percentage of points to decay each day
+	Count    int          `json:"count,omitempty"`    // redistribute: how many of the lowest players to share between
+}
+
+// taxEntry is a single deduction or payout from a tax policy
+type taxEntry struct {
+	nick   string
+	policy string
+	points int // negative for tax, positive for payout
+}
+
+// taxRound keeps track of what the policies do during a round. Policies only record what
+// they would do here, and it's up to the caller to apply the entries to the users.
+type taxRound struct {
+	now       time.Time
+	points    map[string]int // projected points for users changed so far in the round
+	entries   []taxEntry
+	collected int // tax collected so far in the round, available for redistribution
+	target    int // no payout takes a user past this. No limit if 0.
+}
+
+type wealthTax struct {
+	brackets []TaxBracket
+}
+
+type inactivityDecay struct {
+	days    int
+	percent float64
+}
+
+type redistribution struct {
+	count int
+}
+
+func (tpc TaxPolicyConfig) policy() (TaxPolicy, error) {
+	switch tpc.Type {
+	case tpWealth:
+		if len(tpc.Brackets) == 0 {
+			return nil, fmt.Errorf("tax policy %q needs at least one bracket", tpc.Type)
+		}
+		brackets := append([]TaxBracket(nil), tpc.Brackets...)
+		sort.Slice(brackets, func(i, j int) bool {
+			return brackets[i].Above < brackets[j].Above
+		})
+		return wealthTax{brackets: brackets}, nil
+	case tpInactivity:
+		if tpc.Days < 1 || tpc.Percent <= 0 {
+			return nil, fmt.Errorf("tax policy %q needs days and percent above 0", tpc.Type)
+		}
+		return inactivityDecay{days: tpc.Days, percent: tpc.Percent}, nil
+	case tpRedistribute:
+		if tpc.Count < 1 {
+			return nil, fmt.Errorf("tax policy %q needs count above 0", tpc.Type)
+		}
+		return redistribution{count: tpc.Count}, nil
+	default:
+		return nil, fmt.Errorf("unknown tax policy: %q", tpc.Type)
+	}
+}
+
+// score returns the points for the user, including what has happened so far in the round
+func (tr *taxRound) score(u *User) int {
+	if points, found := tr.points[u.Nick]; found {
+		return points
+	}
+	return u.getScore()
+}
+
+// setScore sets the projected points for the user in the round
+func (tr *taxRound) setScore(nick string, points int) {
+	if tr.points == nil {
+		tr.points = make(map[string]int)
+	}
+	tr.points[nick] = points
+}
+
+// add records points for the user in the round
+func (tr *taxRound) add(u *User, policy string, points int) {
+	if points == 0 {
+		return
+	}
+	tr.setScore(u.Nick, tr.score(u)+points)
+	if points < 0 {
+		tr.collected += -points
+	}
+	tr.entries = append(tr.entries, taxEntry{nick: u.Nick, policy: policy, points: points})
+}
+
+func (wealthTax) name() string {
+	return tpWealth
+}
+
+// taxFor calculates the progressive tax for the given points
+func (wt wealthTax) taxFor(points int) int {
+	tax := 0.0
+	for i, b := range wt.brackets {
+		if points <= b.Above {
+			break
+		}
+		upper := points
+		if i+1 < len(wt.brackets) && wt.brackets[i+1].Above < points {
+			upper = wt.brackets[i+1].Above
+		}
+		tax += float64(upper-b.Above) * b.Percent / 100.0
+	}
+	return int(tax)
+}
+
+func (wt wealthTax) apply(c *Channel, tr *taxRound) {
+	us := c.Users.filterActive()
+	if len(us) == 0 {
+		return
+	}
+	sort.Slice(us, func(i, j int) bool {
+		return tr.score(us[i]) > tr.score(us[j])
+	})
+	// No leader if shared first place
+	if len(us) > 1 && tr.score(us[0]) == tr.score(us[1]) {
+		return
+	}
+	tr.add(us[0], wt.name(), -wt.taxFor(tr.score(us[0])))
+}
+
+func (inactivityDecay) name() string {
+	return tpInactivity
+}
+
+func (id inactivityDecay) apply(c *Channel, tr *taxRound) {
+	limit := tr.now.AddDate(0, 0, -id.days)
+	for _, u := range c.Users.filterActive() {
+		points := tr.score(u)
+		if points <= 0 || !u.getLastEntry().Before(limit) {
+			continue
+		}
+		// This runs both after each round, and from the daily maintenance job,
+		// so make sure we only decay once per day
+		if sameDay(u.getLastDecay(), tr.now) {
+			continue
+		}
+		decay := int(float64(points) * id.percent / 100.0)
+		if decay < 1 {
+			decay = 1
+		}
+		tr.add(u, id.name(), -decay)
+	}
+}
+
+func (redistribution) name() string {
+	return tpRedistribute
+}
+
+func (rd redistribution) apply(c *Channel, tr *taxRound) {
+	if tr.collected <= 0 {
+		return
+	}
+	// users that reached the target in this round are not locked yet, so leave out
+	// everyone at the target or above
+	us := make(UserSlice, 0, len(c.Users))
+	for _, u := range c.Users.filterActive() {
+		if tr.target == 0 || tr.score(u) < tr.target {
+			us = append(us, u)
+		}
+	}
+	sort.Slice(us, func(i, j int) bool {
+		return tr.score(us[i]) < tr.score(us[j])
+	})
+	if len(us) > rd.count {
+		us = us[:rd.count]
+	}
+	if len(us) == 0 {
+		return
+	}
+	share := tr.collected / len(us)
+	rest := tr.collected % len(us)
+	tr.collected = 0
+	for _, u := range us {
+		points := share
+		if rest > 0 { // the lowest gets the leftovers first
+			points++
+			rest--
+		}
+		// a payout may reach the target, but never go past it. What's left is kept as collected.
+		if room := tr.target - tr.score(u); tr.target != 0 && points > room {
+			tr.collected += points - room
+			points = room
+		}
+		tr.add(u, rd.name(), points)
+	}
+}
+
+// runTaxPolicies runs all configured tax policies for the channel in order, recording
+// what they do in tr, without changing any users
+func (c *Channel) runTaxPolicies(tr *taxRound, onlyType string) {
+	for _, tpc := range c.TaxPolicies {
+		if onlyType != "" && tpc.Type != onlyType {
+			continue
+		}
+		tp, err := tpc.policy()
+		if err != nil {
+			c.l.Error().
+				Str("func", "runTaxPolicies").
+				Err(err).
+				Send()
+			continue
+		}
+		tp.apply(c, tr)
+	}
+}
+
+// applyTaxEntry applies a single entry from a tax policy to the user
+func (c *Channel) applyTaxEntry(u *User, policy string, points int, now time.Time) {
+	u.addScore(points)
+	if points < 0 {
+		u.addTax(-points)
+		c.publishTax(u.Nick, taxPolicyLabels[policy], -points)
+	}
+	if policy == tpInactivity {
+		u.setLastDecay(now)
+	}
+}
+
+// applyDecay runs and applies only the inactivity tax policies for the channel, for use outside of rounds
+func (c *Channel) applyDecay(tr *taxRound) {
+	c.runTaxPolicies(tr, tpInactivity)
+	for _, te := range tr.entries {
+		c.applyTaxEntry(c.get(te.nick), te.policy, te.points, tr.now)
+	}
+}
@@ -0,0 +1,155 @@
+package leet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaxPolicyConfig(t *testing.T) {
+	invalid := []TaxPolicyConfig{
+		{Type: "nonexistent"},
+		{Type: tpWealth},
+		{Type: tpInactivity, Days: 0, Percent: 10},
+		{Type: tpInactivity, Days: 7, Percent: 0},
+		{Type: tpRedistribute, Count: 0},
+	}
+	for _, tpc := range invalid {
+		if _, err := tpc.policy(); err == nil {
+			t.Errorf("Expected error for invalid config: %+v", tpc)
+		}
+	}
+
+	tp, err := TaxPolicyConfig{Type: tpRedistribute, Count: 2}.policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp.name() != tpRedistribute {
+		t.Errorf("Expected policy %q, got %q", tpRedistribute, tp.name())
+	}
+}
+
+func TestWealthTaxFor(t *testing.T) {
+	tp, err := TaxPolicyConfig{
+		Type: tpWealth,
+		Brackets: []TaxBracket{
+			{Above: 1000, Percent: 10},
+			{Above: 500, Percent: 5},
+		},
+	}.policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := tp.(wealthTax)
+
+	tests := map[int]int{
+		100:  0,
+		500:  0,
+		600:  5,  // 100 * 5%
+		1000: 25, // 500 * 5%
+		1200: 45, // 500 * 5% + 200 * 10%
+	}
+	for points, exp := range tests {
+		if got := wt.taxFor(points); got != exp {
+			t.Errorf("Expected tax %d for %d points, got %d", exp, points, got)
+		}
+	}
+}
+
+func TestRoundTaxPolicies(t *testing.T) {
+	sd := newScoreData()
+	c := sd.get(testChannel)
+	now := time.Now()
+
+	leader := c.get("leader")
+	leader.setScore(1000)
+	leader.setLastEntry(now)
+
+	idle := c.get("idle")
+	idle.setScore(100)
+	idle.setLastEntry(now.AddDate(0, 0, -10))
+
+	low := c.get("low")
+	low.setScore(10)
+	low.setLastEntry(now)
+
+	c.TaxPolicies = []TaxPolicyConfig{
+		{Type: tpWealth, Brackets: []TaxBracket{{Above: 500, Percent: 10}}},
+		{Type: tpInactivity, Days: 7, Percent: 10},
+		{Type: tpRedistribute, Count: 1},
+	}
+
+	c.addNickForRound("low") // alone in the round, and gets 1 point for it
+	rr := c.computeRound(now)
+	policies := 0
+	for _, d := range rr.Deltas {
+		if d.Reason == reasonPolicy {
+			policies++
+		}
+	}
+	if policies != 3 {
+		t.Errorf("Expected 3 policy deltas, got %+v", rr.Deltas)
+	}
+	if got := low.getScore(); got != 10 {
+		t.Errorf("Expected no change before the round is applied, got %d", got)
+	}
+	c.applyRound(rr)
+
+	if got := leader.getScore(); got != 950 {
+		t.Errorf("Expected leader to have 950 points after wealth tax, got %d", got)
+	}
+	if got := idle.getScore(); got != 90 {
+		t.Errorf("Expected idle user to have 90 points after decay, got %d", got)
+	}
+	// 1 for the round, 50 from wealth tax and 10 from decay
+	if got := low.getScore(); got != 71 {
+		t.Errorf("Expected low user to have 71 points after redistribution, got %d", got)
+	}
+}
+
+func TestRedistributionTarget(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	c.get("near").setScore(95)
+	c.get("low").setScore(50)
+	c.get("done").setScore(90)
+
+	// done reached the target in this round, so is not locked yet
+	tr := &taxRound{now: time.Now(), target: 100, collected: 21}
+	tr.setScore("done", 100)
+	redistribution{count: 3}.apply(c, tr)
+
+	expected := map[string]int{"low": 61, "near": 100, "done": 100}
+	for nick, points := range expected {
+		if got := tr.score(c.get(nick)); got != points {
+			t.Errorf("Expected %s to have %d points, got %d", nick, points, got)
+		}
+	}
+	if tr.collected != 5 {
+		t.Errorf("Expected 5 points left undistributed, got %d", tr.collected)
+	}
+}
+
+func TestLastDecayJSON(t *testing.T) {
+	u := &User{Nick: "alice"}
+	jb, err := json.Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(jb), "last_decay") {
+		t.Errorf("Expected no last_decay for a user that never decayed, got: %s", jb)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	u.setLastDecay(now)
+	if jb, err = json.Marshal(u); err != nil {
+		t.Fatal(err)
+	}
+	var loaded User
+	if err := json.Unmarshal(jb, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.getLastDecay().Equal(now) {
+		t.Errorf("Expected last decay %v after loading, got %v", now, loaded.getLastDecay())
+	}
+}
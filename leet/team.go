@@ -0,0 +1,223 @@
+package leet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Team is a group of users in a channel, that gets the sum of the members' rank points each round.
+// A team reaching the target score is locked as a winner, just like a User.
+type Team struct {
+	LastEntry time.Time `json:"last_entry"` // last time the team got points
+	Name      string    `json:"name"`
+	Members   []string  `json:"members"`
+	Points    int       `json:"points"`
+	Locked    bool      `json:"locked"` // true if the team has reached the target score
+}
+
+type (
+	TeamMap   map[string]*Team
+	TeamSlice []*Team
+)
+
+func (tm TeamMap) toSlice() TeamSlice {
+	ts := make(TeamSlice, 0, len(tm))
+	for _, t := range tm {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+func (ts TeamSlice) sortByPointsDesc() TeamSlice {
+	sort.Slice(ts,
+		func(i, j int) bool {
+			if ts[i].Points == ts[j].Points {
+				return ts[i].Name < ts[j].Name
+			}
+			return ts[i].Points > ts[j].Points
+		},
+	)
+	return ts
+}
+
+// winnerRank returns the rank of the given team among the locked teams, starting at 0, or -1 if not locked
+func (tm TeamMap) winnerRank(name string) int {
+	ws := make(TeamSlice, 0, len(tm))
+	for _, t := range tm {
+		if t.Locked {
+			ws = append(ws, t)
+		}
+	}
+	sort.Slice(ws,
+		func(i, j int) bool {
+			return ws[i].LastEntry.Before(ws[j].LastEntry)
+		},
+	)
+	for idx, t := range ws {
+		if t.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// teamOf returns the team the given nick is a member of, or nil. Caller must hold the lock.
+func (c *Channel) teamOf(nick string) *Team {
+	for _, t := range c.Teams {
+		if _, found := inStrSlice(t.Members, nick); found {
+			return t
+		}
+	}
+	return nil
+}
+
+// removeFromTeam removes nick from its team, and removes the team if it's empty and has no points.
+// Caller must hold the lock.
+func (c *Channel) removeFromTeam(nick string) *Team {
+	t := c.teamOf(nick)
+	if t == nil {
+		return nil
+	}
+	idx, _ := inStrSlice(t.Members, nick)
+	t.Members = append(t.Members[:idx], t.Members[idx+1:]...)
+	if len(t.Members) == 0 && t.Points == 0 {
+		delete(c.Teams, t.Name)
+	}
+	return t
+}
+
+func (c *Channel) joinTeam(nick, name string) error {
+	if name == "" {
+		return newMessage("team_no_name", nil)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.teamOf(nick); t != nil && t.Name == name {
+		return newMessage("team_already_member", name)
+	}
+	c.removeFromTeam(nick)
+	if c.Teams == nil {
+		c.Teams = make(TeamMap)
+	}
+	t, found := c.Teams[name]
+	if !found {
+		t = &Team{Name: name}
+		c.Teams[name] = t
+	}
+	t.Members = append(t.Members, nick)
+	return nil
+}
+
+func (c *Channel) leaveTeam(nick string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.removeFromTeam(nick) == nil {
+		return newMessage("team_not_member", nil)
+	}
+	return nil
+}
+
+// computeTeams returns the outcome for each team that got points in the round, where each team gets
+// the sum of rank points its members got, minus overshoot tax. Does not change any team.
+func (c *Channel) computeTeams(scoreMap map[string]int) []TeamLine {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var tls []TeamLine
+	winners := 0
+	for _, t := range c.Teams {
+		if t.Locked {
+			winners++
+		}
+	}
+	for _, t := range c.Teams.toSlice().sortByPointsDesc() {
+		if t.Locked {
+			continue
+		}
+		sum := 0
+		for _, nick := range t.Members {
+			sum += scoreMap[nick]
+		}
+		if sum == 0 {
+			continue
+		}
+		tl := TeamLine{
+			Name:    t.Name,
+			Members: t.Members,
+			Rank:    sum,
+		}
+		tl.OvershootTax = c.getOverShootTaxFor(getTargetScore(), t.Points+sum)
+		tl.Points = t.Points + sum - tl.OvershootTax
+		if tl.Points == getTargetScore() {
+			winners++
+			tl.Winner = winners
+		}
+		tls = append(tls, tl)
+	}
+	return tls
+}
+
+// applyTeams updates the teams with the outcome from computeTeams
+func (c *Channel) applyTeams(tls []TeamLine, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tl := range tls {
+		t, found := c.Teams[tl.Name]
+		if !found {
+			continue
+		}
+		t.Points = tl.Points
+		t.LastEntry = now
+		t.Locked = tl.Winner > 0
+	}
+}
+
+// teamLines returns the standing for all teams in the channel, sorted by points
+func (c *Channel) teamLines() []TeamLine {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var tls []TeamLine
+	for _, t := range c.Teams.toSlice().sortByPointsDesc() {
+		tl := TeamLine{
+			Name:    t.Name,
+			Members: t.Members,
+			Points:  t.Points,
+		}
+		if t.Locked {
+			tl.Winner = c.Teams.winnerRank(t.Name) + 1
+		}
+		tls = append(tls, tl)
+	}
+	return tls
+}
+
+// teamStats returns the team leaderboard for the channel
+func (c *Channel) teamStats() string {
+	return renderTeamStats(c, c.teamLines())
+}
+
+// team handles the "!1337 team" command
+func (c *Channel) team(nick string, args []string) string {
+	if len(args) == 0 {
+		if msg := c.teamStats(); msg != "" {
+			return msg
+		}
+		return c.msg("team_none", nil)
+	}
+	switch args[0] {
+	case "join":
+		name := strings.Join(args[1:], " ")
+		if err := c.joinTeam(nick, name); err != nil {
+			return fmt.Sprintf("%s: %s", nick, c.errMsg(err))
+		}
+		return c.msg("team_joined", tdata{"Nick": nick, "Team": name})
+	case "leave":
+		if err := c.leaveTeam(nick); err != nil {
+			return fmt.Sprintf("%s: %s", nick, c.errMsg(err))
+		}
+		return c.msg("team_left", nick)
+	default:
+		return c.msg("team_usage", args[0])
+	}
+}
@@ -0,0 +1,91 @@
+package leet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJoinLeaveTeam(t *testing.T) {
+	c := newScoreData().get(testChannel)
+
+	if err := c.joinTeam("alice", ""); err == nil {
+		t.Error("Expected error for empty team name")
+	}
+	if err := c.joinTeam("alice", "floor1"); err != nil {
+		t.Error(err)
+	}
+	if err := c.joinTeam("alice", "floor1"); err == nil {
+		t.Error("Expected error when joining the same team twice")
+	}
+	if err := c.joinTeam("alice", "floor2"); err != nil {
+		t.Error(err)
+	}
+	if _, found := c.Teams["floor1"]; found {
+		t.Error("Expected empty team to be removed when last member left")
+	}
+	if team := c.teamOf("alice"); team == nil || team.Name != "floor2" {
+		t.Errorf("Expected alice to be in floor2, got %+v", team)
+	}
+	if err := c.leaveTeam("alice"); err != nil {
+		t.Error(err)
+	}
+	if err := c.leaveTeam("alice"); err == nil {
+		t.Error("Expected error when leaving without a team")
+	}
+}
+
+// playRound scores a round where nicks got on time, in order
+func playRound(c *Channel, nicks ...string) *RoundResult {
+	for _, nick := range nicks {
+		c.addNickForRound(nick)
+	}
+	rr := c.computeRound(time.Now())
+	c.applyRound(rr)
+	return rr
+}
+
+func TestRoundTeams(t *testing.T) {
+	c := newScoreData().get(testChannel)
+	c.OvershootTax = 10
+	limit := getTargetScore()
+
+	for _, nick := range []string{"alice", "bob"} {
+		if err := c.joinTeam(nick, "floor1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.joinTeam("carol", "floor2"); err != nil {
+		t.Fatal(err)
+	}
+	c.Teams["floor1"].Points = limit - 5
+	c.Teams["floor2"].Points = limit - 10
+
+	// alice gets 3 points, bob 2 and carol 1
+	rr := playRound(c, "alice", "bob", "carol")
+	if len(rr.Teams) != 2 {
+		t.Fatalf("Expected results for 2 teams, got %d", len(rr.Teams))
+	}
+
+	floor1 := c.Teams["floor1"]
+	if floor1.Points != limit || !floor1.Locked {
+		t.Errorf("Expected floor1 to reach %d and be locked, got %d, locked: %t", limit, floor1.Points, floor1.Locked)
+	}
+	if c.Teams.winnerRank("floor1") != 0 {
+		t.Errorf("Expected floor1 to be winner #1")
+	}
+
+	// floor2 gets 3 points, overshoots by 2 and is taxed back below the target
+	c.Teams["floor2"].Points = limit - 1
+	playRound(c, "carol", "alice", "bob")
+	if floor1.Points != limit {
+		t.Errorf("Expected locked team to not get more points, got %d", floor1.Points)
+	}
+	if got := c.Teams["floor2"].Points; got != limit-8 {
+		t.Errorf("Expected floor2 to have %d points after overshoot tax, got %d", limit-8, got)
+	}
+
+	if stats := c.teamStats(); !strings.Contains(stats, "floor1") || !strings.Contains(stats, "Winner #1!") {
+		t.Errorf("Unexpected team stats: %q", stats)
+	}
+}
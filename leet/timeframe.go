@@ -16,6 +16,21 @@ const (
 	tcAfter                  // more than a minute late
 )
 
+var timeCodeNames = map[TimeCode]string{
+	tcBefore: "before",
+	tcEarly:  "early",
+	tcOnTime: "on_time",
+	tcLate:   "late",
+	tcAfter:  "after",
+}
+
+func (tc TimeCode) String() string {
+	if name, found := timeCodeNames[tc]; found {
+		return name
+	}
+	return "unknown"
+}
+
 type TimeFrame struct {
 	hour         int
 	minute       int
@@ -76,3 +91,21 @@ func (tf TimeFrame) code(t time.Time) TimeCode {
 func (tf TimeFrame) getTargetScore() int {
 	return tf.hour*100 + tf.minute
 }
+
+// distance returns how far off the target time t is, regardless of direction
+func (tf TimeFrame) distance(t time.Time) time.Duration {
+	t2 := time.Date(
+		t.Year(),
+		t.Month(),
+		t.Day(),
+		tf.hour,
+		tf.minute,
+		0,
+		0,
+		t.Location(),
+	)
+	if t.After(t2) {
+		return t.Sub(t2)
+	}
+	return t2.Sub(t)
+}
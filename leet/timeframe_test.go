@@ -2,6 +2,7 @@ package leet
 
 import (
 	"testing"
+	"time"
 )
 
 func Test_TimeFrame_getTargetScore(t *testing.T) {
@@ -13,3 +14,17 @@ func Test_TimeFrame_getTargetScore(t *testing.T) {
 		t.Fatalf("Expected 1337, got: %d", got)
 	}
 }
+
+func Test_TimeFrame_distance(t *testing.T) {
+	t.Parallel()
+
+	t1 := time.Date(0, 1, 1, 13, 37, 0, 0, time.UTC)
+	tf := TimeFrame{hour: 13, minute: 37}
+
+	if got := tf.distance(t1.Add(5 * time.Millisecond)); got != 5*time.Millisecond {
+		t.Fatalf("Expected 5ms, got: %s", got)
+	}
+	if got := tf.distance(t1.Add(-5 * time.Millisecond)); got != 5*time.Millisecond {
+		t.Fatalf("Expected 5ms, got: %s", got)
+	}
+}
@@ -0,0 +1,274 @@
+package leet
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Match is a head-to-head match in the tournament bracket.
+// The second player is empty if the first player has a bye.
+type Match struct {
+	Players [2]string `json:"players"`
+	Winner  string    `json:"winner,omitempty"`
+}
+
+// Tournament is an optional bracket mode for a channel, where registered players are
+// paired each day, and the one closest to the target time advances.
+type Tournament struct {
+	Started  time.Time `json:"started,omitempty"`
+	Champion string    `json:"champion,omitempty"`
+	Players  []string  `json:"players"` // registered players, in order of registration
+	Stages   [][]Match `json:"stages"`  // the bracket so far, the last stage is the one being played
+	mu       sync.Mutex
+}
+
+// tournamentEntry is what we need to know about a player's entry in the round to decide a match
+type tournamentEntry struct {
+	distance time.Duration
+	entered  bool
+}
+
+func (m Match) isBye() bool {
+	return m.Players[1] == ""
+}
+
+func (m Match) String() string {
+	return newMessage("match", m).Error()
+}
+
+func (t *Tournament) started() bool {
+	return len(t.Stages) > 0
+}
+
+func (t *Tournament) join(nick string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started() {
+		return newMessage("tournament_already_started", nil)
+	}
+	if _, found := inStrSlice(t.Players, nick); found {
+		return newMessage("tournament_already_registered", nick)
+	}
+	t.Players = append(t.Players, nick)
+	return nil
+}
+
+func (t *Tournament) leave(nick string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started() {
+		return newMessage("tournament_already_started", nil)
+	}
+	idx, found := inStrSlice(t.Players, nick)
+	if !found {
+		return newMessage("tournament_not_registered", nick)
+	}
+	t.Players = append(t.Players[:idx], t.Players[idx+1:]...)
+	return nil
+}
+
+// pair creates matches from the given players, in order. If the number of players is odd,
+// the last one gets a bye, and wins without playing.
+func pair(players []string) []Match {
+	matches := make([]Match, 0, (len(players)+1)/2)
+	for i := 0; i < len(players); i += 2 {
+		m := Match{Players: [2]string{players[i]}}
+		if i+1 < len(players) {
+			m.Players[1] = players[i+1]
+		} else {
+			m.Winner = players[i]
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// start closes registration and pairs the players for the first stage
+func (t *Tournament) start(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started() {
+		return newMessage("tournament_already_started", nil)
+	}
+	if len(t.Players) < 2 {
+		return newMessage("tournament_too_few", len(t.Players))
+	}
+	t.Started = now
+	t.Stages = append(t.Stages, pair(t.Players))
+	return nil
+}
+
+// playRound decides all open matches in the current stage from the entries in the round,
+// and moves on to the next stage when all matches are decided. Returns a message for each
+// decided match.
+func (t *Tournament) playRound(entry func(nick string) tournamentEntry) []message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started() || t.Champion != "" {
+		return nil
+	}
+
+	var msgs []message
+	stage := t.Stages[len(t.Stages)-1]
+	for i := range stage {
+		m := &stage[i]
+		if m.Winner != "" {
+			continue
+		}
+		e0, e1 := entry(m.Players[0]), entry(m.Players[1])
+		switch {
+		case e0.entered && e1.entered:
+			winner, loser, wd, ld := m.Players[0], m.Players[1], e0.distance, e1.distance
+			if e1.distance < e0.distance {
+				winner, loser, wd, ld = loser, winner, ld, wd
+			}
+			m.Winner = winner
+			msgs = append(msgs, newMessage("match_won", tdata{
+				"Winner":    winner,
+				"Loser":     loser,
+				"WinnerOff": wd,
+				"LoserOff":  ld,
+			}))
+		case e0.entered:
+			m.Winner = m.Players[0]
+			msgs = append(msgs, newMessage("match_walkover", tdata{"Winner": m.Players[0], "Loser": m.Players[1]}))
+		case e1.entered:
+			m.Winner = m.Players[1]
+			msgs = append(msgs, newMessage("match_walkover", tdata{"Winner": m.Players[1], "Loser": m.Players[0]}))
+		default:
+			msgs = append(msgs, newMessage("match_rematch", *m))
+		}
+	}
+
+	winners := make([]string, 0, len(stage))
+	for _, m := range stage {
+		if m.Winner == "" {
+			return msgs
+		}
+		winners = append(winners, m.Winner)
+	}
+
+	if len(winners) == 1 {
+		t.Champion = winners[0]
+		msgs = append(msgs, newMessage("tournament_champion", t.Champion))
+		return msgs
+	}
+
+	t.Stages = append(t.Stages, pair(winners))
+	msgs = append(msgs, newMessage("tournament_next_stage", tdata{"Stage": len(t.Stages), "Matches": t.Stages[len(t.Stages)-1]}))
+	return msgs
+}
+
+func (t *Tournament) stageString(idx int) string {
+	return newMessage("matches", t.Stages[idx]).Error()
+}
+
+// show returns the registered players, or the bracket if started, with each message
+// rendered by render
+func (t *Tournament) show(render func(message) string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started() {
+		if len(t.Players) == 0 {
+			return render(newMessage("tournament_no_players", nil))
+		}
+		return render(newMessage("tournament_players", strings.Join(t.Players, ", ")))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n", render(newMessage("tournament_started", t.Started.Format("2006-01-02"))))
+	for i, stage := range t.Stages {
+		fmt.Fprintf(&sb, "%s\n", render(newMessage("tournament_stage", tdata{"Stage": i + 1, "Matches": stage})))
+	}
+	if t.Champion != "" {
+		fmt.Fprintf(&sb, "%s\n", render(newMessage("tournament_champion_line", t.Champion)))
+	}
+	return sb.String()
+}
+
+func (t *Tournament) String() string {
+	return t.show(message.Error)
+}
+
+// getTournament returns the tournament for the channel, creating it if needed
+func (c *Channel) getTournament() *Tournament {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Tournament == nil {
+		c.Tournament = &Tournament{}
+	}
+	return c.Tournament
+}
+
+// tournamentEntry returns the entry in the current round for the given nick, to decide matches
+func (c *Channel) tournamentEntry(now time.Time) func(string) tournamentEntry {
+	tf := getTimeFrame()
+	return func(nick string) tournamentEntry {
+		if nick == "" {
+			return tournamentEntry{}
+		}
+		c.mu.RLock()
+		u, found := c.Users[nick]
+		c.mu.RUnlock()
+		if !found || u.getLastEntry().IsZero() || !u.lastTSInCurrentRound(now) {
+			return tournamentEntry{}
+		}
+		return tournamentEntry{
+			entered:  true,
+			distance: tf.distance(u.getLastEntry()),
+		}
+	}
+}
+
+// bracket handles the "!1337 bracket" command. Only admins may start or reset the tournament.
+func (c *Channel) bracket(nick string, admin bool, args []string) string {
+	c.mu.RLock()
+	t := c.Tournament
+	c.mu.RUnlock()
+	if len(args) == 0 {
+		if t == nil {
+			t = &Tournament{} // just to show it, so it's not stored
+		}
+		return t.show(c.render)
+	}
+	onlyAdmins := func() string {
+		return c.msg("bracket_admins_only", tdata{"Nick": nick, "Action": args[0]})
+	}
+	noTournament := func() string {
+		return c.msg("bracket_no_tournament", nick)
+	}
+	var err error
+	switch args[0] {
+	case "join":
+		t = c.getTournament()
+		err = t.join(nick)
+	case "leave":
+		if t == nil {
+			return noTournament()
+		}
+		err = t.leave(nick)
+	case "start":
+		if !admin {
+			return onlyAdmins()
+		}
+		if t == nil {
+			return noTournament()
+		}
+		err = t.start(time.Now())
+	case "reset":
+		if !admin {
+			return onlyAdmins()
+		}
+		c.mu.Lock()
+		c.Tournament = nil
+		c.mu.Unlock()
+		return c.msg("bracket_reset", nil)
+	default:
+		return c.msg("bracket_usage", args[0])
+	}
+	if err != nil {
+		return fmt.Sprintf("%s: %s", nick, c.errMsg(err))
+	}
+	return t.show(c.render)
+}
@@ -0,0 +1,140 @@
+package leet
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+)
+
+func TestTournamentRegistration(t *testing.T) {
+	tm := &Tournament{}
+
+	if err := tm.start(time.Now()); err == nil {
+		t.Error("Expected error when starting with no players")
+	}
+	if err := tm.join("alice"); err != nil {
+		t.Error(err)
+	}
+	if err := tm.join("alice"); err == nil {
+		t.Error("Expected error when joining twice")
+	}
+	if err := tm.join("bob"); err != nil {
+		t.Error(err)
+	}
+	if err := tm.leave("carol"); err == nil {
+		t.Error("Expected error when leaving without being registered")
+	}
+	if err := tm.start(time.Now()); err != nil {
+		t.Error(err)
+	}
+	if err := tm.join("carol"); err == nil {
+		t.Error("Expected error when joining after start")
+	}
+}
+
+func TestTournamentPlayRound(t *testing.T) {
+	tm := &Tournament{}
+	for _, nick := range []string{"alice", "bob", "carol", "dave", "eve"} {
+		if err := tm.join(nick); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tm.start(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(tm.Stages[0]) != 3 || !tm.Stages[0][2].isBye() || tm.Stages[0][2].Winner != "eve" {
+		t.Fatalf("Expected 3 matches with a bye for eve, got: %s", tm.stageString(0))
+	}
+
+	entries := map[string]tournamentEntry{
+		"alice": {entered: true, distance: 5 * time.Millisecond},
+		"bob":   {entered: true, distance: time.Millisecond},
+		"carol": {entered: true, distance: time.Second},
+	}
+	entry := func(nick string) tournamentEntry {
+		return entries[nick]
+	}
+
+	msgs := tm.playRound(entry)
+	if tm.Stages[0][0].Winner != "bob" {
+		t.Errorf("Expected bob to beat alice, got winner %q", tm.Stages[0][0].Winner)
+	}
+	if tm.Stages[0][1].Winner != "carol" {
+		t.Errorf("Expected carol to advance when dave did not show, got winner %q", tm.Stages[0][1].Winner)
+	}
+	if len(tm.Stages) != 2 {
+		t.Fatalf("Expected next stage to be paired, messages: %v", msgs)
+	}
+
+	// bob vs carol, eve gets a bye again. Nobody shows up.
+	entries = map[string]tournamentEntry{}
+	tm.playRound(entry)
+	if len(tm.Stages) != 2 {
+		t.Fatalf("Expected to stay in stage 2 when nobody plays")
+	}
+
+	entries = map[string]tournamentEntry{
+		"bob":   {entered: true, distance: time.Millisecond},
+		"carol": {entered: true, distance: time.Nanosecond},
+	}
+	tm.playRound(entry) // carol wins, and meets eve in the final
+	entries["eve"] = tournamentEntry{entered: true, distance: time.Microsecond}
+	msgs = tm.playRound(entry)
+	if tm.Champion != "carol" {
+		t.Errorf("Expected carol to be champion, got %q. Messages: %v", tm.Champion, msgs)
+	}
+	if !strings.Contains(tm.String(), "Champion: carol") {
+		t.Errorf("Expected champion in bracket output, got: %q", tm.String())
+	}
+}
+
+func TestBracketCommand(t *testing.T) {
+	c := newScoreData().get(testChannel)
+
+	if got := c.bracket("alice", false, nil); !strings.Contains(got, "No players registered") {
+		t.Errorf("Unexpected bracket: %q", got)
+	}
+	if c.Tournament != nil {
+		t.Fatal("Expected no tournament created by showing it")
+	}
+	if got := c.bracket("alice", false, []string{"leave"}); !strings.Contains(got, "no tournament yet") || c.Tournament != nil {
+		t.Errorf("Expected no tournament to leave, got %q", got)
+	}
+
+	c.bracket("alice", false, []string{"join"})
+	c.bracket("bob", false, []string{"join"})
+	if got := c.bracket("alice", false, []string{"start"}); !strings.Contains(got, "only admins") || c.Tournament.started() {
+		t.Errorf("Expected start to be refused, got %q", got)
+	}
+	if got := c.bracket("alice", false, []string{"reset"}); !strings.Contains(got, "only admins") || c.Tournament == nil {
+		t.Errorf("Expected reset to be refused, got %q", got)
+	}
+	if c.bracket("admin", true, []string{"start"}); !c.Tournament.started() {
+		t.Errorf("Expected admin to start the tournament")
+	}
+	if c.bracket("admin", true, []string{"reset"}); c.Tournament != nil {
+		t.Errorf("Expected admin to reset the tournament")
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	admins := _admins
+	t.Cleanup(func() { _admins = admins })
+	_admins = []string{"Alice", "bob@example.org"}
+
+	for _, tc := range []struct {
+		nick, host string
+		want       bool
+	}{
+		{"alice", "anywhere.net", true},
+		{"bob", "example.org", true},
+		{"bob", "evil.net", false},
+		{"carol", "example.org", false},
+	} {
+		if got := isAdmin(&bot.User{Nick: tc.nick, ID: tc.host}); got != tc.want {
+			t.Errorf("isAdmin(%s@%s) = %t, want %t", tc.nick, tc.host, got, tc.want)
+		}
+	}
+}
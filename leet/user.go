@@ -15,16 +15,18 @@ type ScoreTracker struct {
 
 type User struct {
 	l         zerolog.Logger
-	LastEntry time.Time    `json:"last_entry"` // time of last !1337 post that resulted in a score, positive or negative
-	BestEntry time.Time    `json:"best_entry"` // tightest to 1337, or whatever...
-	Nick      string       `json:"nick"`       // duplicate of map key, but we need to have it here as well sometimes
-	Taxes     ScoreTracker `json:"taxes"`      // hos much tax over time
-	Bonuses   ScoreTracker `json:"bonuses"`    // how much bonuses over time
-	Misses    ScoreTracker `json:"misses"`     // how many times have the user been early or late
-	Points    int          `json:"score"`      // current points total
+	LastEntry time.Time    `json:"last_entry"`           // time of last !1337 post that resulted in a score, positive or negative
+	BestEntry time.Time    `json:"best_entry"`           // tightest to 1337, or whatever...
+	Nick      string       `json:"nick"`                 // duplicate of map key, but we need to have it here as well sometimes
+	Taxes     ScoreTracker `json:"taxes"`                // hos much tax over time
+	Bonuses   ScoreTracker `json:"bonuses"`              // how much bonuses over time
+	Misses    ScoreTracker `json:"misses"`               // how many times have the user been early or late
+	LastDecay *time.Time   `json:"last_decay,omitempty"` // last time points decayed because of inactivity, nil if never
+	Points    int          `json:"score"`                // current points total
 	mu        sync.RWMutex
 	didTry    bool
-	Locked    bool `json:"locked"` // true if the user has reached the target limit
+	Locked    bool `json:"locked"`  // true if the user has reached the target limit
+	Retired   bool `json:"retired"` // true if the user has been inactive for too long. Reset on next entry.
 }
 
 type (
@@ -60,9 +62,24 @@ func (um UserMap) filterByLocked(locked bool) UserSlice {
 	return us
 }
 
+// filterActive returns users that are still in the game, i.e. not locked and not retired
+func (um UserMap) filterActive() UserSlice {
+	us := make(UserSlice, 0, len(um))
+	for _, v := range um {
+		if !v.isLocked() && !v.isRetired() {
+			us = append(us, v)
+		}
+	}
+	return us
+}
+
+// longestNickLen returns the length of the longest nick that's shown, i.e. not retired
 func (um UserMap) longestNickLen() int {
 	maxlen := 0
-	for k := range um {
+	for k, v := range um {
+		if v.isRetired() {
+			continue
+		}
 		nlen := len(k)
 		if nlen > maxlen {
 			maxlen = nlen
@@ -213,6 +230,45 @@ func (u *User) isLocked() bool {
 	return u.Locked
 }
 
+func (u *User) isRetired() bool {
+	if u == nil {
+		return false
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.Retired
+}
+
+func (u *User) setRetired(retired bool) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.Retired = retired
+	u.mu.Unlock()
+}
+
+func (u *User) getLastDecay() time.Time {
+	if u == nil {
+		return time.Time{}
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if u.LastDecay == nil {
+		return time.Time{}
+	}
+	return *u.LastDecay
+}
+
+func (u *User) setLastDecay(when time.Time) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.LastDecay = &when
+	u.mu.Unlock()
+}
+
 // Helper functions for comparing times. We can't use time.(Defore|After), since
 // we only want to compare the time part, not the date
 func isBefore(t1, t2 time.Time) bool {
@@ -3,50 +3,63 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/go-chat-bot/bot"
 	"github.com/go-chat-bot/bot/irc"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 
-	"github.com/oddlid/dvdgbot/larsmonsen"
+	"github.com/oddlid/dvdgbot/config"
+	"github.com/oddlid/dvdgbot/health"
 	"github.com/oddlid/dvdgbot/leet"
-	"github.com/oddlid/dvdgbot/morse"
-	"github.com/oddlid/dvdgbot/timestamp"
-	"github.com/oddlid/dvdgbot/util"
-	"github.com/oddlid/dvdgbot/xkcdbot"
-	// "github.com/oddlid/dvdgbot/userwatch"
+	"github.com/oddlid/dvdgbot/metrics"
+	"github.com/oddlid/dvdgbot/plugin"
+	"github.com/oddlid/dvdgbot/reload"
+	"github.com/oddlid/dvdgbot/userwatch"
 )
 
 const (
 	defaultAddress = "irc.oftc.net:6697"
 	defaultUser    = "leetbot"
 	defaultNick    = "leetbot"
+	// name of the config file in the reload registry, sorted before the plugins, so they
+	// reload with the new settings
+	configModule = "config"
 )
 
 const (
-	optServer   = `server`
-	optChannel  = `channel`
-	optUser     = `user`
-	optNick     = `nick`
-	optPass     = `password`
-	optTLS      = `tls`
-	optDebug    = `debug`
-	optLogLevel = `log-level`
+	optServer         = `server`
+	optChannel        = `channel`
+	optUser           = `user`
+	optNick           = `nick`
+	optPass           = `password`
+	optTLS            = `tls`
+	optDebug          = `debug`
+	optLogLevel       = `log-level`
+	optFormat         = `format`
+	optFile           = `file`
+	optHTTPAddr       = `http-addr`
+	optReloadInterval = `reload-interval`
+	optConfig         = `config`
+	optTable          = `table`
 )
 
 const (
-	envIRCServer = `IRC_SERVER`
-	envIRCUser   = `IRC_USER`
-	envICRNick   = `IRC_NICK`
-	envIRCPass   = `IRC_PASS`
-	envIRCTLS    = `IRC_TLS`
-	envDebug     = `DEBUG`
+	envIRCServer      = `IRC_SERVER`
+	envIRCUser        = `IRC_USER`
+	envICRNick        = `IRC_NICK`
+	envIRCPass        = `IRC_PASS`
+	envIRCTLS         = `IRC_TLS`
+	envDebug          = `DEBUG`
+	envLeetFile       = `LEETBOT_SCOREFILE`
+	envHTTPAddr       = `HTTP_ADDR`
+	envReloadInterval = `RELOAD_INTERVAL`
+	envConfig         = `DVDGBOT_CONFIG`
 )
 
 var (
@@ -57,82 +70,174 @@ var (
 )
 
 func entryPoint(cCtx *cli.Context) error {
-	c := irc.Config{
-		Channels: cCtx.StringSlice(optChannel),
-		Server:   cCtx.String(optServer),
-		User:     cCtx.String(optUser),
-		Nick:     cCtx.String(optNick),
-		Password: cCtx.String(optPass),
-		UseTLS:   cCtx.Bool(optTLS),
-		Debug:    cCtx.Bool(optDebug),
-	}
-
-	// If using leet, but not userwatch, do this:
-	b, _ := irc.SetUpConn(&c) // ic should be second return param here if using userwatch module
-	leet.SetParentBot(b)
-
-	// Or, if using both leet and userwatch, do like this instead, and comment the above:
-	// b, ic := irc.SetUpConn(c)
-	// err := userwatch.InitBot(c, b, ic, envDefStr("USERWATCH_CFGFILE", userwatch.DEF_CFGFILE))
-	// if err != nil {
-	// 	return cli.NewExitError(err.Error(), 1)
-	// }
-	// leet.SetParentBot(b)
-
-	lm, err := larsmonsen.New(
-		util.EnvDefStr(
-			larsmonsen.FactsFileEnvVar,
-			larsmonsen.DefaultFactsFile,
-		),
-		larsmonsen.DefaultPattern,
-	)
+	cfg, err := loadConfig(cCtx)
 	if err != nil {
 		return err
 	}
-	bot.RegisterPassiveCommand(
-		larsmonsen.DefaultCommandName,
-		lm.Quote,
-	)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 
-	mb := morse.NewBot()
-	bot.RegisterCommand(
-		morse.A2MCmd,
-		morse.A2MDesc,
-		morse.A2MParams,
-		mb.ToMorse,
-	)
-	bot.RegisterCommand(
-		morse.M2ACmd,
-		morse.M2ADesc,
-		morse.M2AParams,
-		mb.FromMorse,
-	)
+	c := irc.Config{
+		Channels: cfg.IRC.Channels,
+		Server:   cfg.IRC.Server,
+		User:     cfg.IRC.User,
+		Nick:     cfg.IRC.Nick,
+		Password: cfg.IRC.Password,
+		UseTLS:   cfg.IRC.UseTLS(),
+		Debug:    cfg.IRC.Debug,
+	}
 
-	bot.RegisterCommand(
-		timestamp.DefaultCommandName,
-		timestamp.Description,
-		timestamp.Params,
-		timestamp.Prepend,
-	)
+	b, ic := irc.SetUpConn(&c)
+	metrics.Instrument(ic)
 
-	xb := xkcdbot.New(
-		3*time.Second,
-		func() context.Context {
-			return cCtx.Context
-		},
-	)
-	bot.RegisterCommand(
-		xkcdbot.DefaultCommandName,
-		xkcdbot.Description,
-		xkcdbot.Params,
-		xb.Fetch,
-	)
+	for _, e := range cfg.Plugins.List() {
+		if !e.Enabled {
+			continue
+		}
+		host := plugin.Host{
+			Ctx:      cCtx.Context,
+			Bot:      b,
+			Conn:     ic,
+			IRC:      &c,
+			Settings: e.Settings,
+		}
+		if err := plugin.Setup(e.Name, host, e.Channels); err != nil {
+			return err
+		}
+		log.Info().Str("plugin", e.Name).Strs("channels", e.Channels).Msg("Plugin enabled")
+	}
+	plugin.RegisterHelp()
+
+	if cfg.HTTPAddr != "" {
+		mux := http.NewServeMux()
+		if plugin.Enabled(leet.PluginName) {
+			leet.RegisterHandlers(mux)
+		}
+		mux.Handle("/metrics", metrics.Handler())
+		hm := health.New(ic, c.Channels)
+		if plugin.Enabled(leet.PluginName) {
+			hm.AddCheck(leet.PluginName, leet.HealthCheck)
+		}
+		hm.RegisterHandlers(mux)
+		serveHTTP(cCtx.Context, cfg.HTTPAddr, mux)
+	}
+
+	if filename := cCtx.String(optConfig); filename != "" {
+		reload.Register(configModule, reloadConfig(cCtx), filename)
+	}
+	if cfg.ReloadInterval > 0 {
+		go reload.Watch(cCtx.Context, cfg.ReloadInterval)
+	}
 
 	irc.Run(nil) // pass nil here, as we passed c to SetUpConn, so config is done
 
 	return nil
 }
 
+// loadConfig reads the config file, if given, and overrides it with the flags. Flags that are
+// not set only fill in what's missing from the file, so their defaults don't override it.
+func loadConfig(cCtx *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(cCtx.String(optConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	str := func(name string, dst *string) {
+		if cCtx.IsSet(name) || *dst == "" {
+			*dst = cCtx.String(name)
+		}
+	}
+	str(optServer, &cfg.IRC.Server)
+	str(optUser, &cfg.IRC.User)
+	str(optNick, &cfg.IRC.Nick)
+	str(optPass, &cfg.IRC.Password)
+	str(optHTTPAddr, &cfg.HTTPAddr)
+	if cCtx.IsSet(optChannel) || len(cfg.IRC.Channels) == 0 {
+		cfg.IRC.Channels = cCtx.StringSlice(optChannel)
+	}
+	if cCtx.IsSet(optTLS) || cfg.IRC.TLS == nil {
+		useTLS := cCtx.Bool(optTLS)
+		cfg.IRC.TLS = &useTLS
+	}
+	if cCtx.IsSet(optDebug) {
+		cfg.IRC.Debug = cCtx.Bool(optDebug)
+	}
+	if cCtx.IsSet(optReloadInterval) || cfg.ReloadInterval == 0 {
+		cfg.ReloadInterval = cCtx.Duration(optReloadInterval)
+	}
+	if cCtx.IsSet(optLogLevel) {
+		cfg.LogLevel = cCtx.String(optLogLevel)
+	}
+
+	// The log level from flags is set in Before, but the one from the file is only known now
+	if !cCtx.Bool(optDebug) && !cCtx.IsSet(optLogLevel) && cfg.LogLevel != "" {
+		if level, err := zerolog.ParseLevel(cfg.LogLevel); err == nil {
+			zerolog.SetGlobalLevel(level)
+		}
+	}
+
+	return cfg, nil
+}
+
+// reloadConfig returns the reload func for the config file. Only the settings that can change
+// at runtime are applied: the leet channel settings and admins, and the userwatch limits.
+// The rest, like the IRC connection, plugins enabled and the files they use, need a restart.
+func reloadConfig(cCtx *cli.Context) reload.Func {
+	return func() error {
+		cfg, err := loadConfig(cCtx)
+		if err != nil {
+			return err
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if plugin.Enabled(leet.PluginName) {
+			if err := leet.ApplySettings(cfg.Plugins.Leet.Settings); err != nil {
+				return err
+			}
+		}
+		if plugin.Enabled(userwatch.PluginName) {
+			userwatch.ApplySettings(cfg.Plugins.UserWatch.Settings)
+		}
+		return nil
+	}
+}
+
+func configValidate(cCtx *cli.Context) error {
+	cfg, err := loadConfig(cCtx)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	fmt.Fprintln(cCtx.App.Writer, "Config OK")
+	return nil
+}
+
+func leetExport(cCtx *cli.Context) error {
+	filename := cCtx.String(optFile)
+	if filename == "" {
+		cfg, err := config.Load(cCtx.String(optConfig))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		filename = cfg.Plugins.Leet.ScoreFile
+	}
+	err := leet.Export(
+		os.Stdout,
+		filename,
+		cCtx.String(optChannel),
+		cCtx.String(optFormat),
+		cCtx.String(optTable),
+	)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	return nil
+}
+
 func parseTime(in string) time.Time {
 	if t, err := time.Parse(time.RFC3339, in); err == nil {
 		return t
@@ -178,7 +283,60 @@ func newApp() *cli.App {
 			return nil
 		},
 		Action: entryPoint,
+		Commands: []*cli.Command{
+			{
+				Name:  "config",
+				Usage: "Tools for the config file",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "validate",
+						Usage:  "Check the config file, with env vars and flags applied, and the files it refers to",
+						Action: configValidate,
+					},
+				},
+			},
+			{
+				Name:  "leet",
+				Usage: "Tools for the leet game",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "export",
+						Usage:  "Export user stats and round history to stdout",
+						Action: leetExport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    optFormat,
+								Aliases: []string{"f"},
+								Usage:   "Output `format` (json or csv)",
+								Value:   leet.ExportJSON,
+							},
+							&cli.StringFlag{
+								Name:    optTable,
+								Aliases: []string{"t"},
+								Usage:   "`table` to export as csv (users or rounds), as they have different columns",
+								Value:   leet.ExportUsers,
+							},
+							&cli.StringFlag{
+								Name:    optChannel,
+								Aliases: []string{"c"},
+								Usage:   "Only export this `channel`. All channels if not given.",
+							},
+							&cli.StringFlag{
+								Name:    optFile,
+								Usage:   "Score `file` to export from",
+								EnvVars: []string{envLeetFile},
+							},
+						},
+					},
+				},
+			},
+		},
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    optConfig,
+				Usage:   "Read config from YAML `file`. Env vars and flags override the file.",
+				EnvVars: []string{envConfig},
+			},
 			&cli.StringFlag{
 				Name:    optServer,
 				Aliases: []string{"s"},
@@ -218,6 +376,16 @@ func newApp() *cli.App {
 				Value:   true,
 				EnvVars: []string{envIRCTLS},
 			},
+			&cli.StringFlag{
+				Name:    optHTTPAddr,
+				Usage:   "Serve the leet API, scoreboard, metrics and health checks over HTTP on `address`, e.g. \":8080\". Disabled if empty.",
+				EnvVars: []string{envHTTPAddr},
+			},
+			&cli.DurationFlag{
+				Name:    optReloadInterval,
+				Usage:   "Check config files for changes every `interval`, e.g. \"30s\", and reload the plugins using them. Disabled if 0. Send SIGHUP to reload all plugins at any time.",
+				EnvVars: []string{envReloadInterval},
+			},
 			&cli.StringFlag{
 				Name:    optLogLevel,
 				Aliases: []string{"l"},
@@ -237,6 +405,7 @@ func newApp() *cli.App {
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer cancel()
+	go reloadOnHangup(ctx)
 	if err := newApp().RunContext(ctx, os.Args); err != nil {
 		log.Error().Err(err).Send()
 	}
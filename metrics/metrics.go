@@ -0,0 +1,189 @@
+// Package metrics collects runtime metrics for the bot and its plugins, and serves them
+// in the Prometheus text format.
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ircevent "github.com/thoj/go-ircevent"
+)
+
+const (
+	namespace    = "dvdgbot"
+	privateLabel = "private" // channel label for private messages, so we don't get one series per nick
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	messagesReceived = counterVec("messages_received_total", "IRC messages received, per channel.", "channel")
+	messagesSent     = counterVec("messages_sent_total", "IRC messages sent, per channel.", "channel")
+	commands         = counterVec("commands_total", "Commands dispatched, per plugin.", "plugin")
+	pluginErrors     = counterVec("plugin_errors_total", "Errors returned from plugins, like failing to fetch a quote or comic.", "plugin")
+	reconnects       = counter("irc_reconnects_total", "Times the bot has reconnected to the IRC server.")
+
+	leetEntries = counterVec("leet_entries_total", "Leet entries, per time code.", "time_code")
+	leetOffset  = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "leet_entry_offset_seconds",
+		Help:      "Offset from the target time for leet entries inside the time window.",
+		Buckets:   []float64{-60, -30, -10, -1, 0, 0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 90, 120},
+	})
+	ntpOffset = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ntp_offset_seconds",
+		Help:      "The latest NTP offset used for leet entries.",
+	})
+	saveDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "save_duration_seconds",
+		Help:      "Time spent saving leet scores to file.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+	saveFailures = counter("save_failures_total", "Failed saves of leet scores to file.")
+)
+
+// connected is set after the first welcome from the server, so we can tell reconnects from the first connect
+var connected atomic.Bool
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		messagesReceived,
+		messagesSent,
+		commands,
+		pluginErrors,
+		reconnects,
+		leetEntries,
+		leetOffset,
+		ntpOffset,
+		saveDuration,
+		saveFailures,
+	)
+}
+
+func counter(name, help string) prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	})
+}
+
+func counterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, labels)
+}
+
+// channelLabel returns the channel, or privateLabel if target is not a channel
+func channelLabel(target string) string {
+	if strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&") {
+		return strings.ToLower(target)
+	}
+	return privateLabel
+}
+
+// Handler returns the handler for the /metrics endpoint
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func MessageReceived(channel string) {
+	messagesReceived.WithLabelValues(channelLabel(channel)).Inc()
+}
+
+func MessageSent(channel string) {
+	messagesSent.WithLabelValues(channelLabel(channel)).Inc()
+}
+
+func CommandDispatched(plugin string) {
+	commands.WithLabelValues(plugin).Inc()
+}
+
+func PluginError(plugin string) {
+	pluginErrors.WithLabelValues(plugin).Inc()
+}
+
+func Reconnected() {
+	reconnects.Inc()
+}
+
+// LeetEntry counts an entry in the leet game, with the name of its time code
+func LeetEntry(timeCode string) {
+	leetEntries.WithLabelValues(timeCode).Inc()
+}
+
+// LeetEntryOffset records how far from the target time an entry was, negative if before
+func LeetEntryOffset(offset time.Duration) {
+	leetOffset.Observe(offset.Seconds())
+}
+
+func SetNtpOffset(offset time.Duration) {
+	ntpOffset.Set(offset.Seconds())
+}
+
+// Saved records the duration of a save, and counts it as failed if err is not nil
+func Saved(duration time.Duration, err error) {
+	saveDuration.Observe(duration.Seconds())
+	if err != nil {
+		saveFailures.Inc()
+	}
+}
+
+// Command wraps fn, counting each call as dispatched to plugin, and each error returned
+func Command(plugin string, fn func(*bot.Cmd) (string, error)) func(*bot.Cmd) (string, error) {
+	return func(cmd *bot.Cmd) (string, error) {
+		CommandDispatched(plugin)
+		msg, err := fn(cmd)
+		if err != nil {
+			PluginError(plugin)
+		}
+		return msg, err
+	}
+}
+
+// Passive wraps a passive command, counting each error returned. Passive commands see every
+// message, so they are only counted as dispatched if they reply.
+func Passive(plugin string, fn func(*bot.PassiveCmd) (string, error)) func(*bot.PassiveCmd) (string, error) {
+	return func(cmd *bot.PassiveCmd) (string, error) {
+		msg, err := fn(cmd)
+		if err != nil {
+			PluginError(plugin)
+		}
+		if msg != "" || err != nil {
+			CommandDispatched(plugin)
+		}
+		return msg, err
+	}
+}
+
+// Instrument counts messages received, sent and reconnects for the given connection
+func Instrument(ic *ircevent.Connection) {
+	ic.AddCallback("PRIVMSG", func(e *ircevent.Event) {
+		if len(e.Arguments) > 0 {
+			MessageReceived(e.Arguments[0])
+		}
+	})
+	ic.AddCallback("001", func(*ircevent.Event) {
+		if connected.Swap(true) {
+			Reconnected()
+		}
+	})
+	bot.RegisterFilterCommand("metrics", func(cmd *bot.FilterCmd) (string, error) {
+		if cmd.Message != "" {
+			MessageSent(cmd.Target)
+		}
+		return cmd.Message, nil
+	})
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestChannelLabel(t *testing.T) {
+	for in, expected := range map[string]string{
+		"#Blackhole": "#blackhole",
+		"&local":     "&local",
+		"leetbot":    privateLabel,
+	} {
+		if got := channelLabel(in); got != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, in, got)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	MessageReceived("#blackhole")
+	MessageSent("somenick")
+	LeetEntry("on_time")
+	LeetEntryOffset(1500 * time.Millisecond)
+	SetNtpOffset(-250 * time.Millisecond)
+	Saved(time.Millisecond, errors.New("disk full"))
+
+	cmd := Command("xkcd", func(*bot.Cmd) (string, error) { return "", errors.New("timeout") })
+	_, _ = cmd(&bot.Cmd{})
+	passive := Passive("larsmonsen", func(*bot.PassiveCmd) (string, error) { return "", nil })
+	_, _ = passive(&bot.PassiveCmd{})
+
+	out := scrape(t)
+	for _, s := range []string{
+		`dvdgbot_messages_received_total{channel="#blackhole"} 1`,
+		`dvdgbot_messages_sent_total{channel="private"} 1`,
+		`dvdgbot_leet_entries_total{time_code="on_time"} 1`,
+		`dvdgbot_leet_entry_offset_seconds_bucket{le="5"} 1`,
+		`dvdgbot_ntp_offset_seconds -0.25`,
+		`dvdgbot_save_failures_total 1`,
+		`dvdgbot_save_duration_seconds_count 1`,
+		`dvdgbot_commands_total{plugin="xkcd"} 1`,
+		`dvdgbot_plugin_errors_total{plugin="xkcd"} 1`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in output", s)
+		}
+	}
+	// a passive command that doesn't reply has not been dispatched
+	if strings.Contains(out, `plugin="larsmonsen"`) {
+		t.Errorf("Did not expect larsmonsen to be counted")
+	}
+}
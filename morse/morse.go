@@ -6,18 +6,32 @@ import (
 
 	m "github.com/alwindoss/morse"
 	"github.com/go-chat-bot/bot"
+
+	"github.com/oddlid/dvdgbot/plugin"
 )
 
 const (
-	plugin    = `MorseConverter`
-	A2MCmd    = `tomorse`
-	A2MDesc   = `Convert ASCII input to morse`
-	A2MParams = `<input>`
-	M2ACmd    = `frommorse`
-	M2ADesc   = `Convert morse input to ASCII`
-	M2AParams = `<input>`
+	title      = `MorseConverter`
+	PluginName = `morse` // Name in the plugin registry and config
+	A2MCmd     = `tomorse`
+	A2MDesc    = `Convert ASCII input to morse`
+	A2MParams  = `<input>`
+	M2ACmd     = `frommorse`
+	M2ADesc    = `Convert morse input to ASCII`
+	M2AParams  = `<input>`
 )
 
+func init() {
+	plugin.Register(PluginName, setupPlugin)
+}
+
+func setupPlugin(p *plugin.Plugin, _ plugin.Host) error {
+	b := NewBot()
+	p.RegisterCommand(A2MCmd, A2MDesc, A2MParams, b.ToMorse)
+	p.RegisterCommand(M2ACmd, M2ADesc, M2AParams, b.FromMorse)
+	return nil
+}
+
 type Bot struct {
 	h m.Hacker
 }
@@ -45,7 +59,7 @@ func (b *Bot) morse2ascii(morse string) (string, error) {
 }
 
 func usage(cmd, params string) string {
-	return fmt.Sprintf("%s: No input. Usage: !%s %s", plugin, cmd, params)
+	return fmt.Sprintf("%s: No input. Usage: !%s %s", title, cmd, params)
 }
 
 func (b *Bot) ToMorse(cmd *bot.Cmd) (string, error) {
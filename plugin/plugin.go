@@ -0,0 +1,215 @@
+// Package plugin keeps a registry of the bot's plugins. Each plugin registers a factory from
+// its init func, and the bot sets up the ones enabled in its config. A plugin can be limited
+// to some channels, and registers its commands through its *Plugin, so they are only run in
+// those channels.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chat-bot/bot"
+	"github.com/go-chat-bot/bot/irc"
+	ircevent "github.com/thoj/go-ircevent"
+
+	"github.com/oddlid/dvdgbot/metrics"
+)
+
+const (
+	HelpCommand     = `plugins`
+	HelpDescription = `List the plugins active in this channel, with their commands`
+)
+
+// Host is what a plugin gets from the bot when it's set up
+type Host struct {
+	Ctx      context.Context
+	Bot      *bot.Bot
+	Conn     *ircevent.Connection
+	IRC      *irc.Config
+	Settings any // the plugin's own settings type from the config, or nil
+}
+
+// Factory sets up a plugin, registering its commands through p
+type Factory func(p *Plugin, h Host) error
+
+// Plugin is a plugin that has been set up
+type Plugin struct {
+	channels map[string]bool // all channels if empty
+	Name     string
+	commands []string
+}
+
+type registry struct {
+	factories map[string]Factory
+	active    map[string]*Plugin
+	mu        sync.RWMutex
+}
+
+var _registry = newRegistry()
+
+func newRegistry() *registry {
+	return &registry{
+		factories: make(map[string]Factory),
+		active:    make(map[string]*Plugin),
+	}
+}
+
+// Register adds the factory for a plugin. It panics if name is already registered, as
+// that's a bug.
+func Register(name string, f Factory) {
+	_registry.register(name, f)
+}
+
+// Setup runs the factory for the named plugin, limited to the given channels, or all
+// channels if none are given
+func Setup(name string, h Host, channels []string) error {
+	return _registry.setup(name, h, channels)
+}
+
+// Enabled returns true if the named plugin has been set up
+func Enabled(name string) bool {
+	return _registry.get(name) != nil
+}
+
+// ActiveIn returns the plugins set up for channel, sorted by name
+func ActiveIn(channel string) []*Plugin {
+	return _registry.activeIn(channel)
+}
+
+// RegisterHelp registers the command listing the plugins active in the current channel
+func RegisterHelp() {
+	bot.RegisterCommand(
+		HelpCommand,
+		HelpDescription,
+		"",
+		help,
+	)
+}
+
+func (r *registry) register(name string, f Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.factories[name]; found {
+		panic(fmt.Sprintf("plugin: %q registered twice", name))
+	}
+	r.factories[name] = f
+}
+
+func (r *registry) setup(name string, h Host, channels []string) error {
+	r.mu.RLock()
+	f, found := r.factories[name]
+	r.mu.RUnlock()
+	if !found {
+		return fmt.Errorf("plugin: %q is not registered", name)
+	}
+
+	p := &Plugin{Name: name, channels: make(map[string]bool, len(channels))}
+	for _, channel := range channels {
+		p.channels[strings.ToLower(channel)] = true
+	}
+	if err := f(p, h); err != nil {
+		return fmt.Errorf("plugin %q: %w", name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active[name] = p
+	return nil
+}
+
+func (r *registry) get(name string) *Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active[name]
+}
+
+func (r *registry) activeIn(channel string) []*Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ps []*Plugin
+	for _, p := range r.active {
+		if p.Active(channel) {
+			ps = append(ps, p)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].Name < ps[j].Name
+	})
+	return ps
+}
+
+// Active returns true if the plugin should act in channel. A plugin limited to some
+// channels is not active in private messages.
+func (p *Plugin) Active(channel string) bool {
+	return len(p.channels) == 0 || p.channels[strings.ToLower(channel)]
+}
+
+// RegisterCommand registers a command for the plugin, that's only run in the channels
+// the plugin is active in, and counted in the metrics
+func (p *Plugin) RegisterCommand(command, description, params string, fn func(*bot.Cmd) (string, error)) {
+	p.commands = append(p.commands, command)
+	bot.RegisterCommand(command, description, params, p.scoped(fn))
+}
+
+// RegisterPassiveCommand registers a passive command for the plugin, that only sees
+// messages in the channels the plugin is active in
+func (p *Plugin) RegisterPassiveCommand(command string, fn func(*bot.PassiveCmd) (string, error)) {
+	bot.RegisterPassiveCommand(command, p.scopedPassive(fn))
+}
+
+// scoped wraps fn so it's only run, and counted, in the channels the plugin is active in
+func (p *Plugin) scoped(fn func(*bot.Cmd) (string, error)) func(*bot.Cmd) (string, error) {
+	counted := metrics.Command(p.Name, fn)
+	return func(cmd *bot.Cmd) (string, error) {
+		if !p.Active(cmd.Channel) {
+			return "", nil
+		}
+		return counted(cmd)
+	}
+}
+
+func (p *Plugin) scopedPassive(fn func(*bot.PassiveCmd) (string, error)) func(*bot.PassiveCmd) (string, error) {
+	counted := metrics.Passive(p.Name, fn)
+	return func(cmd *bot.PassiveCmd) (string, error) {
+		if !p.Active(cmd.Channel) {
+			return "", nil
+		}
+		return counted(cmd)
+	}
+}
+
+// describe returns the name of the plugin, with its commands if any
+func (p *Plugin) describe() string {
+	if len(p.commands) == 0 {
+		return p.Name
+	}
+	cmds := make([]string, 0, len(p.commands))
+	for _, cmd := range p.commands {
+		cmds = append(cmds, bot.CmdPrefix+cmd)
+	}
+	return fmt.Sprintf("%s (%s)", p.Name, strings.Join(cmds, ", "))
+}
+
+func help(cmd *bot.Cmd) (string, error) {
+	return _registry.help(cmd.Channel), nil
+}
+
+// help returns the plugins active in channel, with their commands
+func (r *registry) help(channel string) string {
+	ps := r.activeIn(channel)
+	if len(ps) == 0 {
+		return "No plugins active here"
+	}
+	descs := make([]string, 0, len(ps))
+	for _, p := range ps {
+		descs = append(descs, p.describe())
+	}
+	return fmt.Sprintf(
+		"Active plugins: %s. Type '%shelp <command>' for details.",
+		strings.Join(descs, ", "),
+		bot.CmdPrefix,
+	)
+}
@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-chat-bot/bot"
+)
+
+const testChannel = "#blackhole"
+
+func echo(cmd *bot.Cmd) (string, error) {
+	return cmd.RawArgs, nil
+}
+
+func TestSetup(t *testing.T) {
+	r := newRegistry()
+	var got Host
+	r.register("echo", func(p *Plugin, h Host) error {
+		got = h
+		p.RegisterCommand("echotest", "Echo", "<text>", echo)
+		return nil
+	})
+	r.register("broken", func(p *Plugin, h Host) error {
+		return errors.New("no config")
+	})
+
+	if err := r.setup("echo", Host{Settings: 42}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Settings != 42 {
+		t.Errorf("Expected settings passed to factory, got %v", got.Settings)
+	}
+	if r.get("echo") == nil {
+		t.Errorf("Expected echo to be active")
+	}
+
+	if err := r.setup("broken", Host{}, nil); err == nil || !strings.Contains(err.Error(), "no config") {
+		t.Errorf("Expected error from factory, got: %v", err)
+	}
+	if r.get("broken") != nil {
+		t.Errorf("Expected failed plugin not to be active")
+	}
+	if err := r.setup("nope", Host{}, nil); err == nil {
+		t.Errorf("Expected error for unregistered plugin")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic when registering twice")
+		}
+	}()
+	r.register("echo", nil)
+}
+
+func TestScope(t *testing.T) {
+	r := newRegistry()
+	noop := func(p *Plugin, h Host) error { return nil }
+	r.register("everywhere", noop)
+	r.register("scoped", func(p *Plugin, h Host) error {
+		p.commands = append(p.commands, "scoped")
+		return nil
+	})
+	if err := r.setup("everywhere", Host{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.setup("scoped", Host{}, []string{"#Other", testChannel}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := r.get("scoped")
+	for channel, want := range map[string]bool{
+		testChannel:  true,
+		"#other":     true,
+		"#elsewhere": false,
+		"somenick":   false,
+	} {
+		if got := p.Active(channel); got != want {
+			t.Errorf("Active(%q) = %v, want %v", channel, got, want)
+		}
+	}
+	if !r.get("everywhere").Active("somenick") {
+		t.Errorf("Expected unscoped plugin active in private messages")
+	}
+
+	fn := p.scoped(echo)
+	if msg, _ := fn(&bot.Cmd{Channel: testChannel, RawArgs: "hi"}); msg != "hi" {
+		t.Errorf("Expected command run in scope, got %q", msg)
+	}
+	if msg, _ := fn(&bot.Cmd{Channel: "#elsewhere", RawArgs: "hi"}); msg != "" {
+		t.Errorf("Expected command ignored out of scope, got %q", msg)
+	}
+	passive := p.scopedPassive(func(cmd *bot.PassiveCmd) (string, error) {
+		return cmd.Raw, nil
+	})
+	if msg, _ := passive(&bot.PassiveCmd{Channel: "#elsewhere", Raw: "hi"}); msg != "" {
+		t.Errorf("Expected passive command ignored out of scope, got %q", msg)
+	}
+
+	if help := r.help(testChannel); help != "Active plugins: everywhere, scoped (!scoped). Type '!help <command>' for details." {
+		t.Errorf("Unexpected help: %q", help)
+	}
+	if help := r.help("#elsewhere"); !strings.Contains(help, "everywhere") || strings.Contains(help, "scoped") {
+		t.Errorf("Expected only unscoped plugin in help, got %q", help)
+	}
+	if help := newRegistry().help(testChannel); help != "No plugins active here" {
+		t.Errorf("Unexpected help without plugins: %q", help)
+	}
+}
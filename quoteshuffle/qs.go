@@ -6,9 +6,12 @@ This is mostly a new version of oddlid/rndlist with added support for loading/sa
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"math/rand"
 	"os"
+	"sort"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -20,9 +23,11 @@ const (
 
 type QuoteData struct {
 	zlog     zerolog.Logger
-	FileName string   `json:"-"`
-	Src      []string `json:"src"`
-	Dst      []string `json:"dst"`
+	OnSave   func(fileName string) `json:"-"` // called after the file is saved, if set
+	FileName string                `json:"-"`
+	Src      []string              `json:"src"`
+	Dst      []string              `json:"dst"`
+	mu       sync.Mutex
 }
 
 func New(fileName string) (*QuoteData, error) {
@@ -80,6 +85,9 @@ func (qd *QuoteData) saveFile(fileName string) error {
 	if err != nil {
 		return err
 	}
+	if qd.OnSave != nil {
+		qd.OnSave(fileName)
+	}
 	qd.zlog.Debug().
 		Str("filename", fileName).
 		Int("bytes", n).
@@ -120,8 +128,8 @@ func (qd *QuoteData) del(id int) {
 }
 
 func (qd *QuoteData) next() string {
-	// Restore backing slice if it's been exhausted from previous runs
-	if qd.len() == 0 {
+	// Restore backing slice if it's been exhausted from previous runs, or was never set
+	if len(qd.Src) == 0 {
 		if len(qd.Dst) > 0 {
 			qd.Src = qd.Dst
 			qd.Dst = nil
@@ -148,5 +156,76 @@ func (qd *QuoteData) next() string {
 
 // Shuffle() is just a wrapper that calls next() and then saveSelf()
 func (qd *QuoteData) Shuffle() (string, error) {
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
 	return qd.next(), qd.saveSelf()
 }
+
+// Next returns a random quote, rotated the same way as Shuffle, but without saving. For quotes
+// kept as part of some other data, that's saved by its owner.
+func (qd *QuoteData) Next() string {
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
+	return qd.next()
+}
+
+// Add adds quote, unless it's already there. It's added to the quotes not yet shown.
+func (qd *QuoteData) Add(quote string) bool {
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
+	for _, q := range qd.Src {
+		if q == quote {
+			return false
+		}
+	}
+	for _, q := range qd.Dst {
+		if q == quote {
+			return false
+		}
+	}
+	qd.Src = append(qd.Src, quote)
+	return true
+}
+
+// Remove removes quote, and returns false if it was not found
+func (qd *QuoteData) Remove(quote string) bool {
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
+	for _, list := range []*[]string{&qd.Src, &qd.Dst} {
+		for idx, q := range *list {
+			if q == quote {
+				*list = append((*list)[:idx], (*list)[idx+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// All returns all quotes, shown or not, sorted, so the order doesn't change with the rotation
+func (qd *QuoteData) All() []string {
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
+	all := make([]string, 0, len(qd.Src)+len(qd.Dst))
+	all = append(all, qd.Src...)
+	all = append(all, qd.Dst...)
+	sort.Strings(all)
+	return all
+}
+
+// Reload reads the quotes from file again, and replaces the current ones only if the file
+// could be read, and has any quotes
+func (qd *QuoteData) Reload() error {
+	fresh, err := (&QuoteData{zlog: qd.zlog}).loadFile(qd.FileName)
+	if err != nil {
+		return err
+	}
+	if len(fresh.Src) == 0 && len(fresh.Dst) == 0 {
+		return errors.New("no quotes in " + qd.FileName)
+	}
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
+	qd.Src = fresh.Src
+	qd.Dst = fresh.Dst
+	return nil
+}
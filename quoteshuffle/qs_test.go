@@ -1,5 +1,43 @@
 package quoteshuffle
 
+import (
+	"testing"
+)
+
+func TestInMemory(t *testing.T) {
+	qd := &QuoteData{}
+	if qd.Next() != "" {
+		t.Errorf("Expected no quote when empty")
+	}
+	for _, q := range []string{"b", "a", "c"} {
+		if !qd.Add(q) {
+			t.Errorf("Expected %q added", q)
+		}
+	}
+	if qd.Add("a") {
+		t.Errorf("Expected duplicate not added")
+	}
+
+	// no repeats until all have been shown
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		seen[qd.Next()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected all 3 quotes before repeating, got %v", seen)
+	}
+	if q := qd.Next(); q == "" {
+		t.Errorf("Expected rotation to start over")
+	}
+
+	if !qd.Remove("b") || qd.Remove("b") {
+		t.Errorf("Expected b removed once")
+	}
+	if all := qd.All(); len(all) != 2 || all[0] != "a" || all[1] != "c" {
+		t.Errorf("Expected [a c], got %v", all)
+	}
+}
+
 // import (
 // 	"testing"
 // )
@@ -0,0 +1,178 @@
+// Package reload keeps a registry of modules that can reload their config at runtime,
+// triggered for all of them at once, e.g. on SIGHUP, or by polling their files for changes.
+//
+// A reload func must validate the new config before using any of it, and return an error
+// while keeping the current config if it's not valid.
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var _log = log.With().Str("package", "reload").Logger()
+
+// Func reloads the config for a module
+type Func func() error
+
+// fileState is what we compare to tell if a file has changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+type module struct {
+	fn    Func
+	files map[string]fileState
+	name  string
+}
+
+type registry struct {
+	modules map[string]*module
+	mu      sync.Mutex
+}
+
+var _registry = &registry{modules: make(map[string]*module)}
+
+func stat(filename string) fileState {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fileState{} // a missing file counts as changed when it appears
+	}
+	return fileState{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// Register adds a module, with the files it reads its config from. Registering the same
+// name again replaces the module.
+func Register(name string, fn Func, files ...string) {
+	_registry.register(name, fn, files...)
+}
+
+// Saved tells the watcher that the bot itself wrote filename, so it's not reloaded
+func Saved(filename string) {
+	_registry.saved(filename)
+}
+
+// All reloads all modules, in order of name, and returns the errors from all that failed
+func All() error {
+	return _registry.all()
+}
+
+// Watch polls the files for all modules every interval until ctx is done, and reloads
+// the modules with changed files
+func Watch(ctx context.Context, interval time.Duration) {
+	_registry.watch(ctx, interval)
+}
+
+func (r *registry) register(name string, fn Func, files ...string) {
+	m := &module{name: name, fn: fn, files: make(map[string]fileState, len(files))}
+	for _, filename := range files {
+		if filename != "" {
+			m.files[filename] = stat(filename)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modules[name] = m
+}
+
+func (r *registry) saved(filename string) {
+	state := stat(filename)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.modules {
+		if _, found := m.files[filename]; found {
+			m.files[filename] = state
+		}
+	}
+}
+
+// sorted returns all modules, sorted by name
+func (r *registry) sorted() []*module {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ms := make([]*module, 0, len(r.modules))
+	for _, m := range r.modules {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].name < ms[j].name
+	})
+	return ms
+}
+
+// hasChanged returns true if any of the files for m have changed since last reload
+func (r *registry) hasChanged(m *module) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for filename, state := range m.files {
+		if stat(filename) != state {
+			_log.Info().Str("module", m.name).Str("filename", filename).Msg("File changed")
+			return true
+		}
+	}
+	return false
+}
+
+// reload runs the reload func for m, after updating the state of its files. The lock is not
+// held while reloading, so the module may call Saved.
+func (r *registry) reload(m *module) error {
+	r.mu.Lock()
+	for filename := range m.files {
+		m.files[filename] = stat(filename)
+	}
+	r.mu.Unlock()
+
+	llog := _log.With().Str("module", m.name).Logger()
+	if err := m.fn(); err != nil {
+		llog.Error().Err(err).Msg("Reload failed, keeping current config")
+		return fmt.Errorf("%s: %w", m.name, err)
+	}
+	llog.Info().Msg("Reloaded")
+	return nil
+}
+
+func (r *registry) all() error {
+	var errs []error
+	for _, m := range r.sorted() {
+		if err := r.reload(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// changed reloads all modules with files that have changed since last time
+func (r *registry) changed() error {
+	var errs []error
+	for _, m := range r.sorted() {
+		if !r.hasChanged(m) {
+			continue
+		}
+		if err := r.reload(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *registry) watch(ctx context.Context, interval time.Duration) {
+	_log.Info().Dur("interval", interval).Msg("Watching config files for changes")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = r.changed() // already logged
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package reload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRegistry() *registry {
+	return &registry{modules: make(map[string]*module)}
+}
+
+func touch(t *testing.T, filename, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAll(t *testing.T) {
+	r := newTestRegistry()
+	var calls []string
+	r.register("b", func() error { calls = append(calls, "b"); return errors.New("bad config") })
+	r.register("a", func() error { calls = append(calls, "a"); return nil })
+
+	err := r.all()
+	if err == nil || !strings.Contains(err.Error(), "b: bad config") {
+		t.Errorf("Expected error from b, got %v", err)
+	}
+	if strings.Join(calls, ",") != "a,b" {
+		t.Errorf("Expected all modules reloaded in order of name, got %v", calls)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "one.json")
+	file2 := filepath.Join(dir, "two.json")
+	then := time.Now().Add(-time.Hour)
+	touch(t, file1, "{}", then)
+	touch(t, file2, "{}", then)
+
+	r := newTestRegistry()
+	reloads := map[string]int{}
+	r.register("one", func() error { reloads["one"]++; return nil }, file1)
+	r.register("two", func() error { reloads["two"]++; return nil }, file2)
+
+	if err := r.changed(); err != nil || len(reloads) != 0 {
+		t.Fatalf("Expected no reloads before any change, got %v, %v", reloads, err)
+	}
+
+	touch(t, file1, `{"a": 1}`, time.Now())
+	_ = r.changed()
+	if reloads["one"] != 1 || reloads["two"] != 0 {
+		t.Errorf("Expected only one reloaded, got %v", reloads)
+	}
+	_ = r.changed()
+	if reloads["one"] != 1 {
+		t.Errorf("Expected no more reloads without changes, got %v", reloads)
+	}
+
+	// changes by the bot itself are not reloaded
+	touch(t, file2, `{"b": 2}`, time.Now())
+	r.saved(file2)
+	_ = r.changed()
+	if reloads["two"] != 0 {
+		t.Errorf("Expected no reload after own save, got %v", reloads)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/oddlid/dvdgbot/reload"
+)
+
+// reloadOnHangup reloads all plugins each time we get SIGHUP, until ctx is done
+func reloadOnHangup(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info().Msg("Got SIGHUP, reloading")
+			if err := reload.All(); err != nil {
+				log.Error().Err(err).Msg("Reload failed for some plugins, they keep their current config")
+			} else {
+				log.Info().Msg("All plugins reloaded")
+			}
+		}
+	}
+}
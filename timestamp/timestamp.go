@@ -12,14 +12,24 @@ import (
 	"time"
 
 	"github.com/go-chat-bot/bot"
+
+	"github.com/oddlid/dvdgbot/plugin"
 )
 
 const (
 	DefaultCommandName = `ts`
 	Description        = `Prepend a message with a detailed timestamp`
 	Params             = `[message]`
+	PluginName         = `timestamp` // Name in the plugin registry and config
 )
 
+func init() {
+	plugin.Register(PluginName, func(p *plugin.Plugin, _ plugin.Host) error {
+		p.RegisterCommand(DefaultCommandName, Description, Params, Prepend)
+		return nil
+	})
+}
+
 func Prepend(cmd *bot.Cmd) (string, error) {
 	t := time.Now()
 	ts := fmt.Sprintf("[%02d:%02d:%02d:%09d]", t.Hour(), t.Minute(), t.Second(), t.Nanosecond())
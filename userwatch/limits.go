@@ -0,0 +1,177 @@
+package userwatch
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the limits in Settings, used by the bot's config when not given
+const (
+	DefaultCooldown      = 10 * time.Minute
+	DefaultChannelLimit  = 5
+	DefaultChannelPeriod = time.Minute
+	DefaultSplitTimeout  = 30 * time.Minute
+)
+
+// A netsplit QUIT has the two servers that split as reason, e.g. "hub.example.net leaf.example.net".
+// Users can't fake it, as the server puts "Quit: " in front of the reason they give.
+var netsplitReason = regexp.MustCompile(`^[^\s.]+(\.[^\s.]+)+ [^\s.]+(\.[^\s.]+)+$`)
+
+// splitDelay is how long to wait after the last user rejoining from a netsplit, before
+// welcoming them all back in one message. Replaced in tests.
+var splitDelay = 10 * time.Second
+
+// limiter keeps users that flap their connection, or lots of them joining at once, from
+// making the bot flood the channel. Limits that are zero or less are off.
+type limiter struct {
+	sent          map[string]time.Time   // key is channel, nick and message type, lowercased
+	recent        map[string][]time.Time // when messages were sent per channel, within the period
+	cooldown      time.Duration          // per nick and message type in each channel
+	channelLimit  int                    // messages per channelPeriod in each channel
+	channelPeriod time.Duration
+	mu            sync.Mutex
+}
+
+func newLimiter(s Settings) *limiter {
+	return &limiter{
+		sent:          make(map[string]time.Time),
+		recent:        make(map[string][]time.Time),
+		cooldown:      s.Cooldown,
+		channelLimit:  s.ChannelLimit,
+		channelPeriod: s.ChannelPeriod,
+	}
+}
+
+// setLimits replaces the limits, keeping what's been sent so far
+func (l *limiter) setLimits(s Settings) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cooldown = s.Cooldown
+	l.channelLimit = s.ChannelLimit
+	l.channelPeriod = s.ChannelPeriod
+}
+
+// allow returns true if a message of msgtype for nick may be sent to channel at now, and
+// if so counts it. An empty nick only checks the channel limit.
+func (l *limiter) allow(channel, nick, msgtype string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := strings.ToLower(channel + " " + nick + " " + msgtype)
+	if nick != "" && l.cooldown > 0 {
+		if last, found := l.sent[key]; found && now.Sub(last) < l.cooldown {
+			return false
+		}
+	}
+
+	channel = strings.ToLower(channel)
+	if l.channelLimit > 0 && l.channelPeriod > 0 {
+		recent := l.recent[channel][:0]
+		for _, t := range l.recent[channel] {
+			if now.Sub(t) < l.channelPeriod {
+				recent = append(recent, t)
+			}
+		}
+		l.recent[channel] = recent
+		if len(recent) >= l.channelLimit {
+			return false
+		}
+		l.recent[channel] = append(recent, now)
+	}
+
+	if nick != "" && l.cooldown > 0 {
+		l.sent[key] = now
+		// forget those past the cooldown, so the map doesn't grow forever
+		for k, t := range l.sent {
+			if now.Sub(t) >= l.cooldown {
+				delete(l.sent, k)
+			}
+		}
+	}
+	return true
+}
+
+// netsplits keeps track of users lost in a netsplit, so we don't say bye when they split,
+// and welcome them back all at once when they return
+type netsplits struct {
+	split   map[string]time.Time // key is channel and nick, lowercased
+	pending map[string][]string  // nicks rejoined per channel, waiting to be welcomed back
+	timeout time.Duration        // how long after the split a rejoin counts as a return from it
+	mu      sync.Mutex
+}
+
+func newNetsplits(timeout time.Duration) *netsplits {
+	return &netsplits{
+		split:   make(map[string]time.Time),
+		pending: make(map[string][]string),
+		timeout: timeout,
+	}
+}
+
+// setTimeout replaces the timeout, keeping the users already lost in a split
+func (ns *netsplits) setTimeout(timeout time.Duration) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.timeout = timeout
+}
+
+func isNetsplit(reason string) bool {
+	return netsplitReason.MatchString(reason)
+}
+
+// quit records that nick was lost from channel in a netsplit
+func (ns *netsplits) quit(channel, nick string, now time.Time) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.split[strings.ToLower(channel+" "+nick)] = now
+	for k, t := range ns.split {
+		if ns.expired(t, now) {
+			delete(ns.split, k)
+		}
+	}
+}
+
+func (ns *netsplits) expired(t, now time.Time) bool {
+	return ns.timeout > 0 && now.Sub(t) >= ns.timeout
+}
+
+// rejoined returns true if nick joining channel is a return from a netsplit
+func (ns *netsplits) rejoined(channel, nick string, now time.Time) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	key := strings.ToLower(channel + " " + nick)
+	t, found := ns.split[key]
+	delete(ns.split, key)
+	return found && !ns.expired(t, now)
+}
+
+// welcome adds nick to the ones to welcome back in channel, and calls flush after splitDelay
+// with no more returning, with all of them
+func (ns *netsplits) welcome(channel, nick string, flush func(channel string, nicks []string)) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	key := strings.ToLower(channel)
+	ns.pending[key] = append(ns.pending[key], nick)
+	count := len(ns.pending[key])
+	time.AfterFunc(splitDelay, func() {
+		ns.mu.Lock()
+		nicks := ns.pending[key]
+		if len(nicks) != count { // more have returned since, and the last one flushes
+			ns.mu.Unlock()
+			return
+		}
+		delete(ns.pending, key)
+		ns.mu.Unlock()
+		flush(channel, nicks)
+	})
+}
+
+// reset forgets everything, e.g. when reconnecting
+func (ns *netsplits) reset() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.split = make(map[string]time.Time)
+	ns.pending = make(map[string][]string)
+}
@@ -0,0 +1,170 @@
+package userwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(Settings{Cooldown: 10 * time.Minute, ChannelLimit: 3, ChannelPeriod: time.Minute})
+	now := time.Now()
+
+	if !l.allow(testChannel, "alice", cmdJoin, now) {
+		t.Fatal("Expected first join allowed")
+	}
+	if l.allow(testChannel, "Alice", cmdJoin, now.Add(time.Minute)) {
+		t.Errorf("Expected join within cooldown denied")
+	}
+	if !l.allow(testChannel, "alice", cmdQuit, now.Add(time.Minute)) {
+		t.Errorf("Expected quit allowed, as cooldown is per type")
+	}
+	if !l.allow(otherChan, "alice", cmdJoin, now.Add(time.Minute)) {
+		t.Errorf("Expected join allowed in another channel")
+	}
+	if !l.allow(testChannel, "alice", cmdJoin, now.Add(10*time.Minute)) {
+		t.Errorf("Expected join allowed after cooldown")
+	}
+
+	// the channel limit
+	now = now.Add(time.Hour)
+	for _, nick := range []string{"a", "b", "c"} {
+		if !l.allow(testChannel, nick, cmdJoin, now) {
+			t.Errorf("Expected %s allowed", nick)
+		}
+	}
+	if l.allow(testChannel, "d", cmdJoin, now.Add(time.Second)) {
+		t.Errorf("Expected d over the channel limit")
+	}
+	if l.allow(testChannel, "", cmdJoin, now.Add(time.Second)) {
+		t.Errorf("Expected channel message over the limit")
+	}
+	if !l.allow(otherChan, "d", cmdJoin, now.Add(time.Second)) {
+		t.Errorf("Expected d allowed in another channel")
+	}
+	if !l.allow(testChannel, "d", cmdJoin, now.Add(time.Minute)) {
+		t.Errorf("Expected d allowed after the period")
+	}
+
+	// zero is no limit
+	l = newLimiter(Settings{})
+	for i := 0; i < 10; i++ {
+		if !l.allow(testChannel, "alice", cmdJoin, now) {
+			t.Fatal("Expected no limits")
+		}
+	}
+
+	// new limits from a reloaded config apply right away
+	l.setLimits(Settings{Cooldown: time.Minute})
+	if !l.allow(testChannel, "bob", cmdJoin, now) {
+		t.Errorf("Expected first join allowed with the new cooldown")
+	}
+	if l.allow(testChannel, "bob", cmdJoin, now.Add(time.Second)) {
+		t.Errorf("Expected join within the new cooldown denied")
+	}
+}
+
+func TestIsNetsplit(t *testing.T) {
+	for reason, want := range map[string]bool{
+		"hub.example.net leaf.example.net": true,
+		"*.net *.split":                    true,
+		"Quit: hub.example.net leaf.net":   false,
+		"Ping timeout: 240 seconds":        false,
+		"Quit: bye":                        false,
+		"example.net":                      false,
+		"":                                 false,
+	} {
+		if got := isNetsplit(reason); got != want {
+			t.Errorf("isNetsplit(%q) = %v, want %v", reason, got, want)
+		}
+	}
+}
+
+func TestFlapping(t *testing.T) {
+	ic, out := setupTest(t)
+	_limiter = newLimiter(Settings{Cooldown: time.Minute})
+	join(ic, testChannel)
+
+	event(ic, cmdJoin, "alice", testChannel)
+	expect(t, out, testChannel+": Welcome, alice")
+	event(ic, cmdQuit, "alice", "Ping timeout")
+	expect(t, out, testChannel+": Bye, alice")
+	for i := 0; i < 3; i++ {
+		event(ic, cmdJoin, "alice", testChannel)
+		event(ic, cmdQuit, "alice", "Ping timeout")
+	}
+	expect(t, out)
+	if u := watchData().Get(testChannel).Get("alice"); u.Joins != 4 {
+		t.Errorf("Expected all joins counted, got %d", u.Joins)
+	}
+}
+
+func TestNetsplit(t *testing.T) {
+	ic, out := setupTest(t)
+	delay := splitDelay
+	splitDelay = 50 * time.Millisecond
+	t.Cleanup(func() { splitDelay = delay })
+	_splits = newNetsplits(time.Minute)
+	join(ic, testChannel, "alice", "bob")
+	join(ic, otherChan, "alice")
+
+	for _, nick := range []string{"alice", "bob"} {
+		event(ic, cmdQuit, nick, "hub.example.net leaf.example.net")
+	}
+	expect(t, out)
+
+	// bob has no messages, so only alice is welcomed back, once per channel
+	event(ic, cmdJoin, "alice", testChannel)
+	event(ic, cmdJoin, "bob", testChannel)
+	expect(t, out, testChannel+": Welcome back from the netsplit, alice")
+	event(ic, cmdJoin, "alice", otherChan)
+	expect(t, out, otherChan+": Welcome back from the netsplit, alice")
+
+	// and after that, she's greeted as usual
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out, testChannel+": Bye, alice", otherChan+": Bye, alice")
+	event(ic, cmdJoin, "alice", testChannel)
+	expect(t, out, testChannel+": Welcome, alice")
+}
+
+func TestNetsplitWelcome(t *testing.T) {
+	delay := splitDelay
+	splitDelay = 50 * time.Millisecond
+	t.Cleanup(func() { splitDelay = delay })
+
+	ns := newNetsplits(time.Minute)
+	now := time.Now()
+	ns.quit(testChannel, "Alice", now)
+	if ns.rejoined(otherChan, "alice", now) {
+		t.Errorf("Expected no split in another channel")
+	}
+	if !ns.rejoined(testChannel, "alice", now.Add(time.Second)) {
+		t.Errorf("Expected alice back from the split")
+	}
+	if ns.rejoined(testChannel, "alice", now.Add(time.Second)) {
+		t.Errorf("Expected alice back only once")
+	}
+	ns.quit(testChannel, "bob", now)
+	if ns.rejoined(testChannel, "bob", now.Add(time.Minute)) {
+		t.Errorf("Expected split timed out")
+	}
+
+	flushed := make(chan []string, 4)
+	for _, nick := range []string{"alice", "bob", "carol"} {
+		ns.welcome(testChannel, nick, func(channel string, nicks []string) {
+			flushed <- nicks
+		})
+	}
+	select {
+	case nicks := <-flushed:
+		if len(nicks) != 3 {
+			t.Errorf("Expected all welcomed at once, got %v", nicks)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for welcome")
+	}
+	select {
+	case nicks := <-flushed:
+		t.Errorf("Expected one welcome, got another for %v", nicks)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
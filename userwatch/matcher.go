@@ -0,0 +1,167 @@
+package userwatch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ircevent "github.com/thoj/go-ircevent"
+)
+
+// Prefixes for the matcher types, as given to "!userwatch add" and stored as the key for
+// each watch entry. A matcher without prefix is a mask if it has "@", else a nick.
+const (
+	prefixNick    = "nick:"
+	prefixMask    = "mask:"
+	prefixAccount = "account:"
+)
+
+// matchKind is the type of matcher, in order of precedence, lowest first
+type matchKind int
+
+const (
+	matchNick matchKind = iota
+	matchMask
+	matchAccount
+)
+
+// Identity is who a user is, as far as we can tell from an IRC event
+type Identity struct {
+	Nick    string
+	User    string
+	Host    string
+	Account string // empty if not logged in, or the server doesn't tell us
+}
+
+// Matcher matches users by nick glob, "user@host" or "nick!user@host" mask, or services
+// account. Nick and mask patterns may use "*" and "?", and all matching ignores case.
+type Matcher struct {
+	pattern string // lowercased, without prefix
+	kind    matchKind
+}
+
+// identityOf returns the identity of the user that caused e. The account is from the
+// account-tag, or from an extended JOIN, where "*" means not logged in.
+func identityOf(e *ircevent.Event) Identity {
+	id := Identity{Nick: e.Nick, User: e.User, Host: e.Host}
+	if account, found := e.Tags["account"]; found {
+		id.Account = account
+	} else if e.Code == cmdJoin && len(e.Arguments) >= 3 && e.Arguments[1] != "*" {
+		id.Account = e.Arguments[1]
+	}
+	return id
+}
+
+// ParseMatcher parses a matcher as given to "!userwatch add"
+func ParseMatcher(spec string) (Matcher, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	var m Matcher
+	switch {
+	case strings.HasPrefix(spec, prefixAccount):
+		m = Matcher{kind: matchAccount, pattern: strings.TrimPrefix(spec, prefixAccount)}
+	case strings.HasPrefix(spec, prefixMask):
+		m = Matcher{kind: matchMask, pattern: strings.TrimPrefix(spec, prefixMask)}
+	case strings.HasPrefix(spec, prefixNick):
+		m = Matcher{kind: matchNick, pattern: strings.TrimPrefix(spec, prefixNick)}
+	case strings.Contains(spec, "@"):
+		m = Matcher{kind: matchMask, pattern: spec}
+	default:
+		m = Matcher{kind: matchNick, pattern: spec}
+	}
+
+	if m.pattern == "" {
+		return m, errors.New("empty matcher")
+	}
+	switch m.kind {
+	case matchAccount:
+		if strings.ContainsAny(m.pattern, "*?") {
+			return m, fmt.Errorf("account can not have wildcards: %q", m.pattern)
+		}
+	case matchMask:
+		if !strings.Contains(m.pattern, "@") {
+			return m, fmt.Errorf("mask must be user@host or nick!user@host: %q", m.pattern)
+		}
+	case matchNick:
+		if strings.ContainsAny(m.pattern, "!@") {
+			return m, fmt.Errorf("invalid nick: %q", m.pattern)
+		}
+	}
+	return m, nil
+}
+
+// String returns the matcher in the form it's stored, which ParseMatcher gives back
+func (m Matcher) String() string {
+	switch m.kind {
+	case matchAccount:
+		return prefixAccount + m.pattern
+	default:
+		return m.pattern // masks are told from nicks by the "@"
+	}
+}
+
+// Match returns true if id matches m
+func (m Matcher) Match(id Identity) bool {
+	switch m.kind {
+	case matchAccount:
+		return id.Account != "" && strings.EqualFold(m.pattern, id.Account)
+	case matchMask:
+		target := id.User + "@" + id.Host
+		if strings.Contains(m.pattern, "!") {
+			target = id.Nick + "!" + target
+		}
+		return globMatch(m.pattern, strings.ToLower(target))
+	default:
+		return globMatch(m.pattern, strings.ToLower(id.Nick))
+	}
+}
+
+// literals returns the number of characters in the pattern that are not wildcards
+func (m Matcher) literals() int {
+	return len(m.pattern) - strings.Count(m.pattern, "*") - strings.Count(m.pattern, "?")
+}
+
+// before returns true if m takes precedence over other, when both match: accounts before
+// masks before nicks, then patterns without wildcards before globs, then the pattern with
+// the most literal characters, and last by pattern, so the result is always the same.
+func (m Matcher) before(other Matcher) bool {
+	if m.kind != other.kind {
+		return m.kind > other.kind
+	}
+	mExact, oExact := !strings.ContainsAny(m.pattern, "*?"), !strings.ContainsAny(other.pattern, "*?")
+	if mExact != oExact {
+		return mExact
+	}
+	if ml, ol := m.literals(), other.literals(); ml != ol {
+		return ml > ol
+	}
+	return m.pattern < other.pattern
+}
+
+// globMatch returns true if s matches pattern, where "*" matches any number of characters,
+// and "?" matches exactly one
+func globMatch(pattern, s string) bool {
+	p, r := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0 // last "*" in pattern, and where in s we tried it
+	for si < len(r) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case pi < len(p) && (p[pi] == '?' || p[pi] == r[si]):
+			pi++
+			si++
+		case star >= 0:
+			// let the last "*" take one more character, and try again from there
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,147 @@
+package userwatch
+
+import (
+	"testing"
+
+	ircevent "github.com/thoj/go-ircevent"
+)
+
+func TestGlobMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"alice", "alice", true},
+		{"alice", "alice2", false},
+		{"ali*", "alice", true},
+		{"ali*", "al", false},
+		{"*ce", "alice", true},
+		{"a?ice", "alice", true},
+		{"a?ice", "aice", false},
+		{"*", "", true},
+		{"a*b*c", "axxbyybc", true},
+		{"a*b*c", "axxbyyb", false},
+		{"~*@*.example.org", "~alice@host.example.org", true},
+		{"*@user/alice", "alice@user/alice", true},
+	} {
+		if got := globMatch(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParseMatcher(t *testing.T) {
+	for spec, want := range map[string]string{
+		"Alice":                  "alice",
+		"nick:Ali*":              "ali*",
+		"~alice@*.example.org":   "~alice@*.example.org",
+		"mask:alice!*@*":         "alice!*@*",
+		"account:AliceAcc":       "account:aliceacc",
+		" ACCOUNT:aliceacc ":     "account:aliceacc",
+		"mask:*!*@alice.example": "*!*@alice.example",
+	} {
+		m, err := ParseMatcher(spec)
+		if err != nil {
+			t.Errorf("ParseMatcher(%q): %v", spec, err)
+			continue
+		}
+		if m.String() != want {
+			t.Errorf("ParseMatcher(%q) = %q, want %q", spec, m.String(), want)
+		}
+		if again, _ := ParseMatcher(m.String()); again != m {
+			t.Errorf("Expected %q to parse back to the same matcher", m.String())
+		}
+	}
+	for _, spec := range []string{"", "account:", "account:ali*", "mask:alice", "nick:alice@host"} {
+		if _, err := ParseMatcher(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestFindPrecedence(t *testing.T) {
+	c := NewWatchData().Get(testChannel)
+	for _, key := range []string{"a*", "ali*", "alice", "*@*.example.org", "account:aliceacc"} {
+		c.Get(key)
+	}
+
+	find := func(id Identity) string {
+		if u := c.Find(id); u != nil {
+			return u.Nick
+		}
+		return ""
+	}
+	for _, tc := range []struct {
+		id   Identity
+		want string
+	}{
+		{Identity{Nick: "bob", User: "bob", Host: "elsewhere"}, ""},
+		{Identity{Nick: "al", User: "al", Host: "elsewhere"}, "a*"},
+		{Identity{Nick: "alicia", User: "al", Host: "elsewhere"}, "ali*"},
+		{Identity{Nick: "Alice", User: "al", Host: "elsewhere"}, "alice"},
+		{Identity{Nick: "alice", User: "al", Host: "host.example.org"}, "*@*.example.org"},
+		{Identity{Nick: "alice", User: "al", Host: "host.example.org", Account: "AliceAcc"}, "account:aliceacc"},
+		{Identity{Nick: "zed", User: "z", Host: "elsewhere", Account: "AliceAcc"}, "account:aliceacc"},
+	} {
+		if got := find(tc.id); got != tc.want {
+			t.Errorf("Find(%+v) = %q, want %q", tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestAddMatchers(t *testing.T) {
+	ic, out := setupTest(t)
+	join(ic, testChannel)
+
+	for spec, msg := range map[string]string{
+		"account:AliceAcc":    "Hi account %s",
+		"*@alice.example.org": "Hi mask %s",
+		"Ali*":                "Hi glob %s",
+	} {
+		if _, err := add(testChannel, spec, cmdJoin, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := add(testChannel, "account:ali*", cmdJoin, "nope"); err == nil {
+		t.Errorf("Expected error for invalid matcher")
+	}
+	// the same matcher in another case adds to the same entry
+	if _, err := add(testChannel, "ALI*", cmdJoin, "Hi glob %s!"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := del(testChannel, "ali*", cmdJoin, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ls(testChannel, "", ""); got != title+" matchers: *@alice.example.org, account:aliceacc, ali*, alice" {
+		t.Errorf("Unexpected list: %q", got)
+	}
+
+	joinAs := func(nick, host string, tags map[string]string, args ...string) {
+		ic.RunCallbacks(&ircevent.Event{
+			Code:      cmdJoin,
+			Nick:      nick,
+			User:      "~" + nick,
+			Host:      host,
+			Tags:      tags,
+			Arguments: append([]string{testChannel}, args...),
+		})
+	}
+	joinAs("alice2", "elsewhere", nil)
+	expect(t, out, testChannel+": Hi glob alice2!")
+	joinAs("alice", "elsewhere", nil)
+	expect(t, out, testChannel+": Welcome, alice")
+	joinAs("bob", "alice.example.org", nil)
+	expect(t, out, testChannel+": Hi mask bob")
+	joinAs("zed", "elsewhere", nil, "AliceAcc", "Alice Real")
+	expect(t, out, testChannel+": Hi account zed")
+	joinAs("zed", "elsewhere", map[string]string{"account": "aliceacc"})
+	expect(t, out, testChannel+": Hi account zed")
+	joinAs("zed", "elsewhere", nil, "*", "Not logged in")
+	expect(t, out)
+
+	if _, err := del(testChannel, "account:ALICEACC", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	joinAs("zed", "elsewhere", nil, "AliceAcc", "Alice Real")
+	expect(t, out)
+}
@@ -0,0 +1,114 @@
+package userwatch
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prefixes the server may put in front of nicks in a NAMES reply, for ops, voice etc.
+const namesPrefixes = "~&@%+"
+
+type memberChannel struct {
+	nicks map[string]bool // lowercased
+	name  string          // as given by the server
+}
+
+// members keeps track of which nicks are in the channels the bot is in, from NAMES replies,
+// and the JOIN, PART, KICK, NICK and QUIT events after that. Nicks and channels are matched
+// without case.
+type members struct {
+	channels map[string]*memberChannel // key is lowercased channel name
+	mu       sync.Mutex
+}
+
+func newMembers() *members {
+	return &members{channels: make(map[string]*memberChannel)}
+}
+
+func (m *members) channel(name string) *memberChannel {
+	key := strings.ToLower(name)
+	mc, found := m.channels[key]
+	if !found {
+		mc = &memberChannel{name: name, nicks: make(map[string]bool)}
+		m.channels[key] = mc
+	}
+	return mc
+}
+
+// add adds nicks to channel, stripping any NAMES prefixes
+func (m *members) add(channel string, nicks ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mc := m.channel(channel)
+	for _, nick := range nicks {
+		if nick = strings.TrimLeft(nick, namesPrefixes); nick != "" {
+			mc.nicks[strings.ToLower(nick)] = true
+		}
+	}
+}
+
+// remove removes nick from channel
+func (m *members) remove(channel, nick string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mc, found := m.channels[strings.ToLower(channel)]; found {
+		delete(mc.nicks, strings.ToLower(nick))
+	}
+}
+
+// leave forgets channel, when the bot is no longer in it, or is about to get a new NAMES list
+func (m *members) leave(channel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.channels, strings.ToLower(channel))
+}
+
+// reset forgets all channels, e.g. when reconnecting
+func (m *members) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.channels = make(map[string]*memberChannel)
+}
+
+// has returns true if nick is in channel
+func (m *members) has(channel, nick string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mc, found := m.channels[strings.ToLower(channel)]
+	return found && mc.nicks[strings.ToLower(nick)]
+}
+
+// rename moves oldNick to newNick in all channels, and returns the names of the channels
+// it's in, sorted
+func (m *members) rename(oldNick, newNick string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldNick, newNick = strings.ToLower(oldNick), strings.ToLower(newNick)
+	var channels []string
+	for _, mc := range m.channels {
+		if mc.nicks[oldNick] {
+			delete(mc.nicks, oldNick)
+			mc.nicks[newNick] = true
+			channels = append(channels, mc.name)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// quit removes nick from all channels, and returns the names of the channels it was in, sorted
+func (m *members) quit(nick string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nick = strings.ToLower(nick)
+	var channels []string
+	for _, mc := range m.channels {
+		if mc.nicks[nick] {
+			delete(mc.nicks, nick)
+			channels = append(channels, mc.name)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
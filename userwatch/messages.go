@@ -0,0 +1,260 @@
+package userwatch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/oddlid/dvdgbot/quoteshuffle"
+)
+
+// MsgData is the data given to message templates, e.g. "Welcome back {{.Nick}}, gone for {{.Since}}"
+type MsgData struct {
+	LastSeen time.Time // zero if never seen before
+	Nick     string
+	Channel  string
+	Reason   string // for quit or part, if given
+	Since    string // time since last seen in the channel, e.g. "3 days", or empty if never seen before
+	Joins    int    // times seen joining, including this time
+}
+
+// maxMsgLen is how much of a rendered message we keep, as more would not fit on an IRC line anyway
+const maxMsgLen = 400
+
+// errMsgTooLong stops rendering a template when it has written maxMsgLen
+var errMsgTooLong = errors.New("message too long")
+
+// parseMsg parses msg as a template if it has any actions, or returns nil if it's plain text.
+// Anyone can add messages, so templates may only fill in fields, optionally inside if or with.
+func parseMsg(msg string) (*template.Template, error) {
+	if !strings.Contains(msg, "{{") {
+		return nil, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("define and block are not allowed")
+	}
+	if err := checkNodes(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// checkNodes returns an error for anything in the template but text, fields, if and with
+func checkNodes(list *parse.ListNode) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		var err error
+		switch n := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			err = checkPipe(n.Pipe)
+		case *parse.IfNode:
+			err = checkBranch(&n.BranchNode)
+		case *parse.WithNode:
+			err = checkBranch(&n.BranchNode)
+		default:
+			err = fmt.Errorf("%q is not allowed, only fields, if and with", node.String())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkPipe(n.Pipe); err != nil {
+		return err
+	}
+	if err := checkNodes(n.List); err != nil {
+		return err
+	}
+	return checkNodes(n.ElseList)
+}
+
+// checkPipe only allows a single field, or dot, without variables, functions or arguments
+func checkPipe(pipe *parse.PipeNode) error {
+	if len(pipe.Decl) == 0 && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		switch pipe.Cmds[0].Args[0].(type) {
+		case *parse.FieldNode, *parse.DotNode:
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not allowed, only fields like {{.Nick}}", pipe.String())
+}
+
+// limitWriter fails when more than max bytes have been written, to stop a template early
+type limitWriter struct {
+	sb  strings.Builder
+	max int
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if room := lw.max - lw.sb.Len(); len(p) > room {
+		lw.sb.Write(p[:room])
+		return room, errMsgTooLong
+	}
+	return lw.sb.Write(p)
+}
+
+// renderMsg fills in msg with d. A plain message has any "%s" replaced with the nick, as
+// before there were templates.
+func renderMsg(msg string, d MsgData) string {
+	tmpl, err := parseMsg(msg)
+	if err != nil {
+		_log.Error().Err(err).Str("msg", msg).Msg("Invalid message template")
+		return msg
+	}
+	if tmpl == nil {
+		return GetMsg(msg, d.Nick)
+	}
+	lw := &limitWriter{max: maxMsgLen}
+	if err := tmpl.Execute(lw, d); err != nil && !errors.Is(err, errMsgTooLong) {
+		_log.Error().Err(err).Str("msg", msg).Msg("Failed to render message template")
+		return msg
+	}
+	return lw.sb.String()
+}
+
+// humanDuration returns d in the largest whole unit, e.g. "3 days"
+func humanDuration(d time.Duration) string {
+	unit := func(num int, name string) string {
+		if num == 1 {
+			return "1 " + name
+		}
+		return fmt.Sprintf("%d %ss", num, name)
+	}
+	switch {
+	case d < time.Minute:
+		return "a moment"
+	case d < time.Hour:
+		return unit(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return unit(int(d/time.Hour), "hour")
+	default:
+		return unit(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// msgs returns the messages for msgtype, creating the list if needed. The caller must hold the lock.
+func (u *User) msgs(msgtype string) *quoteshuffle.QuoteData {
+	list := &u.Join
+	if !mtype(msgtype, cmdJoin) {
+		list = &u.Quit
+	}
+	if *list == nil {
+		*list = &quoteshuffle.QuoteData{}
+	}
+	return *list
+}
+
+// migrate moves single messages from older versions to the lists
+func (u *User) migrate() {
+	u.Lock()
+	defer u.Unlock()
+	if u.JMsg != "" {
+		u.msgs(cmdJoin).Add(u.JMsg)
+		u.JMsg = ""
+	}
+	if u.QMsg != "" {
+		u.msgs(cmdQuit).Add(u.QMsg)
+		u.QMsg = ""
+	}
+}
+
+// AddMsg adds msg for msgtype (JOIN, or PART/QUIT), and returns false if it was already there
+func (u *User) AddMsg(msgtype, msg string) bool {
+	u.Lock()
+	defer u.Unlock()
+	return u.msgs(msgtype).Add(msg)
+}
+
+// Msgs returns all messages for msgtype, in the order used for DelMsg
+func (u *User) Msgs(msgtype string) []string {
+	u.Lock()
+	defer u.Unlock()
+	return u.msgs(msgtype).All()
+}
+
+// DelMsg deletes message number num, counting from 1, as listed by Msgs
+func (u *User) DelMsg(msgtype string, num int) error {
+	u.Lock()
+	defer u.Unlock()
+	all := u.msgs(msgtype).All()
+	if num < 1 || num > len(all) {
+		return fmt.Errorf("no %s message #%d", strings.ToUpper(msgtype), num)
+	}
+	u.msgs(msgtype).Remove(all[num-1])
+	return nil
+}
+
+// SetJMsg replaces all join messages with msg, or deletes them if msg is empty
+func (u *User) SetJMsg(msg string) {
+	u.setMsg(cmdJoin, msg)
+}
+
+// SetQMsg replaces all quit/part messages with msg, or deletes them if msg is empty
+func (u *User) SetQMsg(msg string) {
+	u.setMsg(cmdQuit, msg)
+}
+
+func (u *User) setMsg(msgtype, msg string) {
+	u.Lock()
+	defer u.Unlock()
+	if mtype(msgtype, cmdJoin) {
+		u.Join = nil
+	} else {
+		u.Quit = nil
+	}
+	if msg != "" {
+		u.msgs(msgtype).Add(msg)
+	}
+}
+
+// empty returns true if there are no messages of any type
+func (u *User) empty() bool {
+	return !u.hasMsgs(cmdJoin) && !u.hasMsgs(cmdQuit)
+}
+
+// hasMsgs returns true if there are any messages for msgtype
+func (u *User) hasMsgs(msgtype string) bool {
+	u.Lock()
+	defer u.Unlock()
+	list := u.Join
+	if !mtype(msgtype, cmdJoin) {
+		list = u.Quit
+	}
+	return list != nil && len(list.All()) > 0
+}
+
+// seen counts the user joining, if msgtype is a join, and returns d with the number of
+// joins filled in
+func (u *User) seen(msgtype string, d MsgData) MsgData {
+	u.Lock()
+	defer u.Unlock()
+	if mtype(msgtype, cmdJoin) {
+		u.Joins++
+	}
+	d.Joins = u.Joins
+	return d
+}
+
+// nextMsg returns the next message for msgtype, filled in with d, or empty if there are none
+func (u *User) nextMsg(msgtype string, d MsgData) string {
+	u.Lock()
+	msg := u.msgs(msgtype).Next()
+	u.Unlock()
+	if msg == "" {
+		return ""
+	}
+	return renderMsg(msg, d)
+}
@@ -0,0 +1,179 @@
+package userwatch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// greet records u as seen, and returns the next message
+func greet(u *User, msgtype string, d MsgData) string {
+	return u.nextMsg(msgtype, u.seen(msgtype, d))
+}
+
+func TestHumanDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Second, "a moment"},
+		{time.Minute, "1 minute"},
+		{59 * time.Minute, "59 minutes"},
+		{90 * time.Minute, "1 hour"},
+		{23 * time.Hour, "23 hours"},
+		{24 * time.Hour, "1 day"},
+		{100 * time.Hour, "4 days"},
+	} {
+		if got := humanDuration(tc.d); got != tc.want {
+			t.Errorf("humanDuration(%v) = %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestRenderMsg(t *testing.T) {
+	d := MsgData{Nick: "alice", Channel: testChannel, Since: "3 days", Reason: "Ping timeout", Joins: 4}
+	for msg, want := range map[string]string{
+		"Welcome, %s":                              "Welcome, alice",
+		"{{.Nick}} is back in {{.Channel}}":        "alice is back in #blackhole",
+		"Gone for {{.Since}}, visit #{{.Joins}}":   "Gone for 3 days, visit #4",
+		"{{if .Reason}}Oops: {{.Reason}}{{end}}":   "Oops: Ping timeout",
+		"{{if .Since}}Back{{else}}Hello{{end}} %s": "Back %s",
+	} {
+		if got := renderMsg(msg, d); got != want {
+			t.Errorf("renderMsg(%q) = %q, want %q", msg, got, want)
+		}
+	}
+	for _, msg := range []string{"{{.Nick", "{{.Nope}}"} {
+		if got := renderMsg(msg, d); got != msg {
+			t.Errorf("Expected invalid template %q as is, got %q", msg, got)
+		}
+	}
+	if _, err := add(testChannel, "alice", cmdJoin, "{{.Nick"); err == nil {
+		t.Errorf("Expected error for invalid template")
+	}
+
+	// anyone can add messages, so only fields are allowed
+	for _, msg := range []string{
+		"{{range 1000000000}}x{{end}}",
+		`{{define "x"}}{{.Nick}}{{end}}{{template "x" .}}`,
+		`{{block "x" .}}{{.Nick}}{{end}}`,
+		`{{printf "%0999999d" 1}}`,
+		`{{$x := .Nick}}{{$x}}`,
+		`{{if eq .Joins 1}}First{{end}}`,
+		`{{.LastSeen.Format "2006"}}`,
+	} {
+		if _, err := parseMsg(msg); err == nil {
+			t.Errorf("Expected %q not allowed", msg)
+		}
+		if got := renderMsg(msg, d); got != msg {
+			t.Errorf("Expected %q as is, got %q", msg, got)
+		}
+	}
+	if _, err := parseMsg("{{with .Reason}}{{.}}{{else}}{{.Nick}}{{end}}"); err != nil {
+		t.Errorf("Expected with and dot allowed, got: %v", err)
+	}
+
+	// nothing renders past what fits on a line
+	d.Reason = strings.Repeat("x", 2*maxMsgLen)
+	if got := renderMsg("{{.Nick}}: {{.Reason}}{{.Reason}}", d); len(got) != maxMsgLen || !strings.HasPrefix(got, "alice: x") {
+		t.Errorf("Expected message cut at %d, got %d bytes", maxMsgLen, len(got))
+	}
+}
+
+func TestRotation(t *testing.T) {
+	u := &User{Nick: "alice"}
+	msgs := []string{"one %s", "two %s", "three %s"}
+	for _, msg := range msgs {
+		if !u.AddMsg(cmdJoin, msg) {
+			t.Errorf("Expected %q to be added", msg)
+		}
+	}
+	if u.AddMsg(cmdJoin, msgs[0]) {
+		t.Errorf("Expected duplicate not to be added")
+	}
+
+	for round := 0; round < 2; round++ {
+		seen := make(map[string]bool)
+		for range msgs {
+			got := greet(u, cmdJoin, MsgData{Nick: "alice"})
+			if seen[got] {
+				t.Errorf("Got %q twice in round %d", got, round)
+			}
+			seen[got] = true
+		}
+		for _, msg := range msgs {
+			if want := GetMsg(msg, "alice"); !seen[want] {
+				t.Errorf("Expected %q in round %d", want, round)
+			}
+		}
+	}
+	if u.Joins != 2*len(msgs) {
+		t.Errorf("Expected %d joins, got %d", 2*len(msgs), u.Joins)
+	}
+	if got := greet(u, cmdQuit, MsgData{Nick: "alice"}); got != "" {
+		t.Errorf("Expected no quit message, got %q", got)
+	}
+
+	if err := u.DelMsg(cmdJoin, 4); err == nil {
+		t.Errorf("Expected error for message out of range")
+	}
+	if err := u.DelMsg(cmdJoin, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(u.Msgs(cmdJoin), ", "); got != "three %s, two %s" {
+		t.Errorf("Unexpected messages after delete: %q", got)
+	}
+}
+
+func TestSeenData(t *testing.T) {
+	ic, out := setupTest(t)
+	join(ic, testChannel)
+	for msgtype, msg := range map[string]string{
+		cmdJoin: "{{.Nick}} #{{.Joins}}{{if .Since}} after {{.Since}}{{end}}",
+		cmdQuit: "{{.Nick}} left {{.Channel}}: {{.Reason}}, after {{.Since}}",
+	} {
+		if _, err := add(testChannel, "bob", msgtype, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// moves when bob was last seen back by d
+	back := func(d time.Duration) {
+		c := watchData().Get(testChannel)
+		c.Lock()
+		c.Seen["bob"].Time = c.Seen["bob"].Time.Add(-d)
+		c.Unlock()
+	}
+
+	event(ic, cmdJoin, "bob", testChannel)
+	expect(t, out, testChannel+": bob #1")
+	back(2 * time.Hour)
+	event(ic, cmdPart, "bob", testChannel, "lunch")
+	expect(t, out, testChannel+": bob left #blackhole: lunch, after 2 hours")
+	back(3 * 24 * time.Hour)
+	event(ic, cmdJoin, "bob", testChannel)
+	expect(t, out, testChannel+": bob #2 after 3 days")
+}
+
+func TestMigrate(t *testing.T) {
+	legacy := `{"channels": {"#blackhole": {"users": {"alice": {"jmsg": "Hi %s", "qmsg": "Bye %s"}}}}}`
+	wd := NewWatchData()
+	if err := wd.Load(strings.NewReader(legacy)); err != nil {
+		t.Fatal(err)
+	}
+	u := wd.Get(testChannel).Get("alice")
+	if got := greet(u, cmdJoin, MsgData{Nick: "Alice"}); got != "Hi Alice" {
+		t.Errorf("Unexpected join message: %q", got)
+	}
+	if got := u.Msgs(cmdQuit); len(got) != 1 || got[0] != "Bye %s" {
+		t.Errorf("Unexpected quit messages: %q", got)
+	}
+
+	var buf bytes.Buffer
+	if _, err := wd.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "jmsg") || strings.Contains(buf.String(), "qmsg") {
+		t.Errorf("Expected legacy fields gone after save: %s", buf.String())
+	}
+}
@@ -0,0 +1,250 @@
+package userwatch
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+	ircevent "github.com/thoj/go-ircevent"
+)
+
+const (
+	cmdPrivmsg string = "PRIVMSG"
+	cmdAction  string = "CTCP_ACTION" // "/me ...", as ircevent gives it
+	// actNickFrom is recorded for the new nick after a nick change, with the old nick as
+	// message, while the old nick gets cmdNick with the new one
+	actNickFrom string = "NICK_FROM"
+	// maxTells is how many messages can wait for the same nick in a channel
+	maxTells = 5
+	// saveInterval is how often we save what's been said at most. Joins and such are saved
+	// right away.
+	saveInterval = time.Minute
+)
+
+// Seen is the last thing a nick was seen doing in a channel
+type Seen struct {
+	Time    time.Time `json:"time"`
+	Nick    string    `json:"nick"`              // as last seen, the key is lowercased
+	Action  string    `json:"action"`            // JOIN, PART, QUIT, KICK, NICK, NICK_FROM or PRIVMSG
+	Message string    `json:"message,omitempty"` // what was said, the reason for leaving, or the other nick
+}
+
+// Tell is a message left with "!tell", for when the nick is next seen in the channel
+type Tell struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from"`
+	Message string    `json:"message"`
+}
+
+var _saved struct {
+	at    time.Time
+	dirty bool
+	sync.Mutex
+}
+
+// changed marks the data as in need of saving
+func changed() {
+	_saved.Lock()
+	_saved.dirty = true
+	_saved.Unlock()
+}
+
+// save saves the data if changed. Unless now is true, it waits until saveInterval has
+// passed since the last save, so we don't write the file for every line said in a channel.
+// Whatever is left is saved with the next join, part or quit.
+func save(now bool) {
+	_saved.Lock()
+	defer _saved.Unlock()
+	if !_saved.dirty || (!now && time.Since(_saved.at) < saveInterval) {
+		return
+	}
+	if err := watchData().SaveFile(_cfgfile); err != nil {
+		_log.Error().Err(err).Str("filename", _cfgfile).Msg("Failed to save")
+		return
+	}
+	_saved.at = time.Now()
+	_saved.dirty = false
+}
+
+// saw records what nick did in the channel
+func (c *Channel) saw(nick, action, msg string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	if c.Seen == nil {
+		c.Seen = make(map[string]*Seen)
+	}
+	c.Seen[strings.ToLower(nick)] = &Seen{Time: now, Nick: nick, Action: action, Message: msg}
+}
+
+// LastSeen returns a copy of what nick was last seen doing in the channel, or nil if never seen
+func (c *Channel) LastSeen(nick string) *Seen {
+	c.RLock()
+	defer c.RUnlock()
+	s, found := c.Seen[strings.ToLower(nick)]
+	if !found {
+		return nil
+	}
+	cp := *s
+	return &cp
+}
+
+// addTell leaves msg for nick, and returns an error if there are too many waiting already
+func (c *Channel) addTell(nick string, t Tell) error {
+	c.Lock()
+	defer c.Unlock()
+	key := strings.ToLower(nick)
+	if len(c.Tells[key]) >= maxTells {
+		return fmt.Errorf("%s already has %d messages waiting", nick, maxTells)
+	}
+	if c.Tells == nil {
+		c.Tells = make(map[string][]Tell)
+	}
+	c.Tells[key] = append(c.Tells[key], t)
+	return nil
+}
+
+// takeTells returns and removes the messages waiting for nick
+func (c *Channel) takeTells(nick string) []Tell {
+	c.Lock()
+	defer c.Unlock()
+	key := strings.ToLower(nick)
+	tells := c.Tells[key]
+	delete(c.Tells, key)
+	return tells
+}
+
+// record records what nick did in channel, if the plugin is active there
+func record(channel, nick, action, msg string) {
+	if !_plugin.Active(channel) {
+		return
+	}
+	watchData().Get(channel).saw(nick, action, msg, time.Now())
+	changed()
+}
+
+// deliver sends the messages left for nick with "!tell" to channel, if the plugin is active
+// there. It's only called when nick joins or says something, so they're there to see them.
+func deliver(channel, nick string, e *ircevent.Event) {
+	if !_plugin.Active(channel) {
+		return
+	}
+	tells := watchData().Get(channel).takeTells(nick)
+	if len(tells) == 0 {
+		return
+	}
+	changed()
+	now := time.Now()
+	for _, t := range tells {
+		send(channel, fmt.Sprintf(
+			"%s: %s asked me to tell you, %s ago: %s",
+			nick,
+			t.From,
+			humanDuration(now.Sub(t.Time)),
+			t.Message,
+		), e)
+	}
+}
+
+// onPRIVMSG records what's said in channels, and delivers messages waiting for the speaker
+func onPRIVMSG(e *ircevent.Event) {
+	channel := e.Arguments[0]
+	if isMe(channel) || isMe(e.Nick) { // private message, or our own
+		return
+	}
+	msg := e.Message()
+	if e.Code == cmdAction {
+		msg = "* " + e.Nick + " " + msg
+	}
+	record(channel, e.Nick, cmdPrivmsg, msg)
+	deliver(channel, e.Nick, e)
+	save(false)
+}
+
+// describe returns what s says nick was doing, e.g. `saying "hi"`
+func (s *Seen) describe() string {
+	withReason := func(what string) string {
+		if s.Message == "" {
+			return what
+		}
+		return fmt.Sprintf("%s (%s)", what, s.Message)
+	}
+	switch s.Action {
+	case cmdJoin:
+		return "joining"
+	case cmdPart:
+		return withReason("leaving")
+	case cmdQuit:
+		return withReason("quitting")
+	case cmdKick:
+		return withReason("being kicked")
+	case cmdNick:
+		return "changing nick to " + s.Message
+	case actNickFrom:
+		return "changing nick from " + s.Message
+	default:
+		return fmt.Sprintf("saying %q", s.Message)
+	}
+}
+
+func isChannel(target string) bool {
+	return strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&")
+}
+
+func seen(cmd *bot.Cmd) (string, error) {
+	if len(cmd.Args) == 0 {
+		return "Usage: !seen <nick>", nil
+	}
+	// what's said in a secret or keyed channel is not for anyone outside it to see
+	if !isChannel(cmd.Channel) {
+		return "I only tell what I've seen in a channel, so use !seen there", nil
+	}
+	nick := cmd.Args[0]
+	switch {
+	case isMe(nick):
+		return "I'm right here", nil
+	case strings.EqualFold(nick, cmd.User.Nick):
+		return "Looking for yourself?", nil
+	case _members.has(cmd.Channel, nick):
+		return fmt.Sprintf("%s is here right now", nick), nil
+	}
+	s := watchData().Get(cmd.Channel).LastSeen(nick)
+	if s == nil {
+		return fmt.Sprintf("I haven't seen %s", nick), nil
+	}
+	return fmt.Sprintf(
+		"%s was last seen in %s %s ago, %s",
+		s.Nick,
+		cmd.Channel,
+		humanDuration(time.Since(s.Time)),
+		s.describe(),
+	), nil
+}
+
+func tell(cmd *bot.Cmd) (string, error) {
+	if len(cmd.Args) < 2 {
+		return "Usage: !tell <nick> <message>", nil
+	}
+	if !isChannel(cmd.Channel) {
+		return "Messages are delivered in the channel they're left in, so use !tell there", nil
+	}
+	nick := cmd.Args[0]
+	switch {
+	case isMe(nick):
+		return "I know", nil
+	case strings.EqualFold(nick, cmd.User.Nick):
+		return "Tell yourself", nil
+	}
+	t := Tell{
+		Time:    time.Now(),
+		From:    cmd.User.Nick,
+		Message: strings.Join(cmd.Args[1:], " "),
+	}
+	if err := watchData().Get(cmd.Channel).addTell(nick, t); err != nil {
+		return err.Error(), nil
+	}
+	changed()
+	save(true)
+	return fmt.Sprintf("OK, I'll tell %s when they're next here", nick), nil
+}
@@ -0,0 +1,132 @@
+package userwatch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-chat-bot/bot"
+)
+
+func command(fn func(*bot.Cmd) (string, error), channel, nick string, args ...string) string {
+	ret, _ := fn(&bot.Cmd{Channel: channel, User: &bot.User{Nick: nick}, Args: args})
+	return ret
+}
+
+func TestSeen(t *testing.T) {
+	ic, out := setupTest(t)
+	join(ic, testChannel, "alice", "bob")
+	join(ic, otherChan)
+
+	event(ic, cmdPrivmsg, "alice", testChannel, "hello")
+	if got := command(seen, testChannel, "bob", "Alice"); got != "Alice is here right now" {
+		t.Errorf("Unexpected: %q", got)
+	}
+	event(ic, cmdPart, "alice", testChannel, "lunch")
+	expect(t, out, testChannel+": Bye, alice")
+
+	for _, tc := range []struct {
+		channel string
+		nick    string
+		want    string
+	}{
+		{testChannel, "alice", "alice was last seen in #blackhole a moment ago, leaving (lunch)"},
+		{testChannel, "ALICE", "alice was last seen in #blackhole a moment ago, leaving (lunch)"},
+		{"bob", "alice", "I only tell what I've seen in a channel, so use !seen there"}, // private
+		{otherChan, "alice", "I haven't seen alice"},
+		{testChannel, "zed", "I haven't seen zed"},
+		{testChannel, "bob", "Looking for yourself?"},
+		{testChannel, testNick, "I'm right here"},
+	} {
+		if got := command(seen, tc.channel, "bob", tc.nick); got != tc.want {
+			t.Errorf("seen %s in %s = %q, want %q", tc.nick, tc.channel, got, tc.want)
+		}
+	}
+
+	event(ic, cmdNick, "bob", "robert")
+	if got := command(seen, testChannel, "carol", "bob"); got != "bob was last seen in #blackhole a moment ago, changing nick to robert" {
+		t.Errorf("Unexpected: %q", got)
+	}
+	event(ic, cmdAction, "robert", testChannel, "waves")
+	if s := watchData().Get(testChannel).LastSeen("robert"); s == nil || s.describe() != `saying "* robert waves"` {
+		t.Errorf("Unexpected: %+v", s)
+	}
+	event(ic, cmdQuit, "robert", "Quit: gone")
+	if got := command(seen, testChannel, "carol", "robert"); got != `robert was last seen in #blackhole a moment ago, quitting (Quit: gone)` {
+		t.Errorf("Unexpected: %q", got)
+	}
+
+	// it's all saved
+	wd, err := NewWatchData().loadFile(_cfgfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := wd.Get(testChannel).LastSeen("Alice"); s == nil || s.Action != cmdPart || s.Message != "lunch" {
+		t.Errorf("Unexpected saved: %+v", s)
+	}
+}
+
+func TestTell(t *testing.T) {
+	ic, out := setupTest(t)
+	join(ic, testChannel, "bob", "carol")
+
+	if got := command(tell, testChannel, "bob", "Alice", "hi", "there"); got != "OK, I'll tell Alice when they're next here" {
+		t.Errorf("Unexpected: %q", got)
+	}
+	if got := command(tell, "bob", "bob", "alice", "hi"); !strings.Contains(got, "use !tell there") {
+		t.Errorf("Expected no tells in private, got %q", got)
+	}
+	event(ic, cmdJoin, "alice", testChannel)
+	expect(t, out, testChannel+": Welcome, alice", testChannel+": alice: bob asked me to tell you, a moment ago: hi there")
+	event(ic, cmdPrivmsg, "alice", testChannel, "thanks")
+	expect(t, out)
+
+	// delivered when speaking, too
+	command(tell, testChannel, "alice", "carol", "ping")
+	event(ic, cmdPrivmsg, "carol", testChannel, "hi")
+	expect(t, out, testChannel+": carol: alice asked me to tell you, a moment ago: ping")
+
+	// waiting tells are kept when leaving, and delivered on the next join
+	command(tell, testChannel, "bob", "carol", "later")
+	event(ic, cmdPart, "carol", testChannel, "lunch")
+	event(ic, cmdKick, "op", testChannel, "carol", "behave")
+	event(ic, cmdQuit, "carol", "Quit: bye")
+	expect(t, out)
+	event(ic, cmdJoin, "carol", testChannel)
+	expect(t, out, testChannel+": carol: bob asked me to tell you, a moment ago: later")
+
+	// and when changing to the nick
+	command(tell, testChannel, "bob", "carol_", "hello")
+	event(ic, cmdNick, "carol", "carol_")
+	expect(t, out, testChannel+": carol_: bob asked me to tell you, a moment ago: hello")
+
+	for i := 0; i < maxTells; i++ {
+		command(tell, testChannel, "bob", "dave", "hi")
+	}
+	if got := command(tell, testChannel, "bob", "dave", "hi"); !strings.Contains(got, "already has") {
+		t.Errorf("Expected too many tells, got %q", got)
+	}
+
+	// waiting tells are saved
+	wd, err := NewWatchData().loadFile(_cfgfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(wd.Get(testChannel).takeTells("Dave")); got != maxTells {
+		t.Errorf("Expected %d saved tells, got %d", maxTells, got)
+	}
+}
+
+func TestSeenScoped(t *testing.T) {
+	ic, _ := setupTest(t, otherChan)
+	join(ic, testChannel, "alice")
+	join(ic, otherChan, "alice")
+
+	event(ic, cmdPrivmsg, "alice", testChannel, "hello")
+	event(ic, cmdPrivmsg, "alice", otherChan, "hi")
+	if s := watchData().Get(testChannel).LastSeen("alice"); s != nil {
+		t.Errorf("Expected nothing recorded where not active, got %+v", s)
+	}
+	if s := watchData().Get(otherChan).LastSeen("alice"); s == nil || s.Message != "hi" {
+		t.Errorf("Unexpected: %+v", s)
+	}
+}
@@ -7,9 +7,9 @@ Runs commands / sends messages when this happens.
 NOTE: This plugin does not work as the normal plugins for "go-chat-bot",
       as this one needs a handle to both the bot instance and the ircevent.Connection
       instance, so just importing this prefixed with underscore and rely on init()
-      will not work. We need to have a custom setup function, where we add callbacks
-      to the ircevent.Connection instance. This should then be called by the importing
-      package before irc.Run().
+      will not work. It registers a factory in the plugin registry instead, where we
+      add callbacks to the ircevent.Connection instance. This is run by the bot before
+      irc.Run(), if the plugin is enabled in the config.
 
 - Odd E. Ebbesen, 2019-02-07 18:32
 
@@ -21,107 +21,207 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chat-bot/bot"
-	"github.com/go-chat-bot/bot/irc"
 	"github.com/rs/zerolog/log"
 	ircevent "github.com/thoj/go-ircevent"
+
+	"github.com/oddlid/dvdgbot/plugin"
+	"github.com/oddlid/dvdgbot/quoteshuffle"
+	"github.com/oddlid/dvdgbot/reload"
 )
 
 const (
 	cmdJoin   string = "JOIN"
 	cmdPart   string = "PART"
 	cmdQuit   string = "QUIT"
+	cmdKick   string = "KICK"
+	cmdNick   string = "NICK"
 	cmdAdd    string = "ADD"
 	cmdDel    string = "DEL"
 	cmdList   string = "LS"
 	cmdReload string = "RELOAD"
 	cmdClear  string = "CLEAR"
-	plugin    string = "UserWatch"
-	// defaultConfigFile string = "/tmp/userwatch.json"
+	title     string = "UserWatch"
+	// IRC numerics
+	rplWelcome  string = "001"
+	rplNamReply string = "353"
+	// PluginName is the name in the plugin registry and config
+	PluginName string = "userwatch"
 )
 
+// Settings for the plugin, as given in the bot's config file
+type Settings struct {
+	ConfigFile    string        `yaml:"config_file"`
+	Cooldown      time.Duration `yaml:"cooldown"`       // between messages of the same type for a nick
+	ChannelLimit  int           `yaml:"channel_limit"`  // messages per channel_period in a channel
+	ChannelPeriod time.Duration `yaml:"channel_period"` // the period for channel_limit
+	SplitTimeout  time.Duration `yaml:"split_timeout"`  // how long to wait for users lost in a netsplit
+}
+
+// IRCv3 capabilities for matching by account
+var wantedCaps = []string{"account-tag", "extended-join"}
+
 var (
-	_bot     *bot.Bot
-	_cfg     *irc.Config
-	_conn    *ircevent.Connection
+	_plugin *plugin.Plugin
+	_bot    *bot.Bot
+	_conn   *ircevent.Connection
+	sendRaw = func(line string) { _conn.SendRaw(line) } // replaced in tests
+	// replaced by Reload and clear, so use watchData()
 	_wd      *WatchData
+	_wdMu    sync.RWMutex
+	_members = newMembers()
+	_limiter = newLimiter(Settings{})
+	_splits  = newNetsplits(0)
 	_cfgfile string
-	_log     = log.With().Str("plugin", plugin).Logger()
+	_log     = log.With().Str("plugin", title).Logger()
 )
 
 type User struct {
-	Nick string `json:"-"` // used for internal access, not needed in storage
-	JMsg string `json:"jmsg,omitempty"`
-	QMsg string `json:"qmsg,omitempty"`
+	Join  *quoteshuffle.QuoteData `json:"join,omitempty"` // rotated, so they're all shown before any repeats
+	Quit  *quoteshuffle.QuoteData `json:"quit,omitempty"`
+	Nick  string                  `json:"-"`              // the matcher, used for internal access, not needed in storage
+	JMsg  string                  `json:"jmsg,omitempty"` // single message from older versions, moved to Join on load
+	QMsg  string                  `json:"qmsg,omitempty"` // same, moved to Quit
+	Joins int                     `json:"joins,omitempty"`
 	sync.RWMutex
 }
 
 type Channel struct {
-	Users map[string]*User `json:"users"`
+	Users map[string]*User  `json:"users"`
+	Seen  map[string]*Seen  `json:"seen,omitempty"`  // key is the lowercased nick
+	Tells map[string][]Tell `json:"tells,omitempty"` // same
 	sync.RWMutex
 }
 
 type WatchData struct {
 	Modified time.Time           `json:"modified"`
 	Channels map[string]*Channel `json:"channels"`
+	mu       sync.RWMutex        // guards Channels, as callbacks run concurrently
 }
 
-func InitBot(cfg *irc.Config, b *bot.Bot, conn *ircevent.Connection, cfgfile string) error {
+func init() {
+	plugin.Register(PluginName, setupPlugin)
+}
+
+func setupPlugin(p *plugin.Plugin, h plugin.Host) error {
+	s, _ := h.Settings.(Settings)
+	if s.ConfigFile == "" {
+		return fmt.Errorf("%s: no config file given", title)
+	}
 	_log.Debug().
 		Msg("Initializing UserWatch")
-	_cfg = cfg
-	_bot = b
-	_conn = conn
-	_cfgfile = cfgfile
-	reload() // initializes _wd
-
+	_plugin = p
+	_bot = h.Bot
+	_conn = h.Conn
+	_cfgfile = s.ConfigFile
+	setWatchData(NewWatchData().LoadFile(_cfgfile)) // will return new instance on error
+
+	_members = newMembers()
+	_limiter = newLimiter(s)
+	_splits = newNetsplits(s.SplitTimeout)
+	_conn.AddCallback(rplWelcome, onWelcome)
+	_conn.AddCallback(rplNamReply, onNAMES)
 	_conn.AddCallback(cmdJoin, onJOIN)
+	_conn.AddCallback(cmdPart, onPART)
+	_conn.AddCallback(cmdKick, onKICK)
+	_conn.AddCallback(cmdNick, onNICK)
 	_conn.AddCallback(cmdQuit, onQUIT)
-	_conn.AddCallback(cmdPart, onQUIT)
+	_conn.AddCallback(cmdPrivmsg, onPRIVMSG)
+	_conn.AddCallback(cmdAction, onPRIVMSG)
 
-	register()
+	register(p)
+	reload.Register(PluginName, Reload, _cfgfile)
 
 	return nil
 }
 
-func register() {
+// ApplySettings replaces the limits with the ones from a reloaded config file. The config
+// file for the watch data is only read at start.
+func ApplySettings(s Settings) {
+	_limiter.setLimits(s)
+	_splits.setTimeout(s.SplitTimeout)
+}
+
+func register(p *plugin.Plugin) {
 	// register command for interacting with this module
 	// Arguments:
-	//	add <nick> <join|part|quit> <msg>
-	//	del <nick> [join|part|quit]
-	//	ls  [nick] [join|part|quit]
+	//	add <matcher> <join|part|quit> <msg>
+	//	del <matcher> [join|part|quit] [num]
+	//	ls  [matcher] [join|part|quit]
 	//	reload
 	//	clear (not in doc output by design)
 	m := []string{
-		"nick",
+		"matcher",
 		"JOIN|PART|QUIT",
 	}
 	argex := fmt.Sprintf(
 		`arguments...
 Where arguments can be one of:
   %s <%s> <%s> <msg>
-  %s <%s> [%s]
+  %s <%s> [%s] [num]
   %s  [%s] [%s]
   %s
 
+Where matcher is one of:
+  nick, or a nick glob with * and ?, e.g. MyNick*
+  user@host or nick!user@host mask, with * and ?, e.g. ~me@*.example.org
+  account:name, for a services account
+If several match, an account wins over a mask, and a mask over a nick. Then exact
+matches win over globs, and longer globs over shorter.
+
+Each add adds another message, and they are used in random order, without repeats
+until all have been used. del with a number deletes just that message, as numbered
+by ls. A message may use "%%s" for the nick, or these template fields:
+  {{.Nick}} {{.Channel}} {{.Joins}} (times joined) {{.Since}} (time since last seen
+  in the channel, e.g. "3 days", empty the first time) {{.Reason}} (for quit/part)
+
 Examples:
   !userwatch %s MyNick %s Welcome, handsome %s
+  !userwatch %s account:MyAccount %s Welcome back, %s
+  !userwatch %s MyNick %s {{.Nick}} is back after {{.Since}}, visit #{{.Joins}}
+  !userwatch %s MyNick %s 2
   !userwatch %s MyNick
   !userwatch %s MyNick
   !userwatch %s
 `,
-		cmdAdd, m[0], m[1], cmdDel, m[0], m[1], cmdList, m[0], m[1], cmdReload, cmdAdd, cmdJoin, "%s", cmdDel, cmdList, cmdList,
+		cmdAdd, m[0], m[1], cmdDel, m[0], m[1], cmdList, m[0], m[1], cmdReload, cmdAdd, cmdJoin, "%s", cmdAdd, cmdJoin, "%s", cmdAdd, cmdJoin, cmdDel, cmdJoin, cmdDel, cmdList, cmdList,
 	)
-	bot.RegisterCommand(
+	p.RegisterCommand(
 		"userwatch",
 		"Display messages when users joins or quits/parts",
 		argex,
 		userwatch,
 	)
+	p.RegisterCommand(
+		"seen",
+		"Tell when a nick was last seen, and what it was doing",
+		"<nick>",
+		seen,
+	)
+	p.RegisterCommand(
+		"tell",
+		"Leave a message for a nick, delivered when it next joins or says something here",
+		"<nick> <message>",
+		tell,
+	)
+}
+
+// watchData returns the current data, which may be replaced by a reload at any time
+func watchData() *WatchData {
+	_wdMu.RLock()
+	defer _wdMu.RUnlock()
+	return _wd
+}
+
+func setWatchData(wd *WatchData) {
+	_wdMu.Lock()
+	defer _wdMu.Unlock()
+	_wd = wd
 }
 
 func NewWatchData() *WatchData {
@@ -132,6 +232,8 @@ func NewWatchData() *WatchData {
 }
 
 func (wd *WatchData) Get(channel string) *Channel {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
 	c, found := wd.Channels[channel]
 	if !found {
 		_log.Debug().
@@ -146,8 +248,7 @@ func (wd *WatchData) Get(channel string) *Channel {
 }
 
 func (wd *WatchData) Save(w io.Writer) (int, error) {
-	wd.Modified = time.Now() // update timestamp
-	jb, err := json.MarshalIndent(wd, "", "\t")
+	jb, err := wd.marshal()
 	if err != nil {
 		return 0, err
 	}
@@ -155,6 +256,23 @@ func (wd *WatchData) Save(w io.Writer) (int, error) {
 	return w.Write(jb)
 }
 
+// marshal returns wd as JSON, with every channel and user locked so callbacks can't change
+// them halfway through
+func (wd *WatchData) marshal() ([]byte, error) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	wd.Modified = time.Now() // update timestamp
+	for _, c := range wd.Channels {
+		c.RLock()
+		defer c.RUnlock()
+		for _, u := range c.Users {
+			u.RLock()
+			defer u.RUnlock()
+		}
+	}
+	return json.MarshalIndent(wd, "", "\t")
+}
+
 func (wd *WatchData) SaveFile(filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
@@ -165,6 +283,7 @@ func (wd *WatchData) SaveFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	reload.Saved(filename)
 	_log.Debug().
 		Str("filename", filename).
 		Int("bytes", n).
@@ -177,25 +296,39 @@ func (wd *WatchData) Load(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(jb, wd)
+	if err := json.Unmarshal(jb, wd); err != nil {
+		return err
+	}
+	// the nick is not stored, as it's the key
+	for _, c := range wd.Channels {
+		for nick, u := range c.Users {
+			u.Nick = nick
+			u.migrate()
+		}
+	}
+	return nil
 }
 
-func (wd *WatchData) LoadFile(filename string) *WatchData {
+func (wd *WatchData) loadFile(filename string) (*WatchData, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		_log.Error().
-			Err(err).
-			Str("filename", filename).
-			Send()
-		return wd
+		return wd, err
 	}
 	defer file.Close()
 
 	if err = wd.Load(file); err != nil {
+		return NewWatchData(), err
+	}
+	return wd, nil
+}
+
+func (wd *WatchData) LoadFile(filename string) *WatchData {
+	wd, err := wd.loadFile(filename)
+	if err != nil {
 		_log.Error().
 			Err(err).
+			Str("filename", filename).
 			Send()
-		return NewWatchData()
 	}
 	return wd
 }
@@ -215,15 +348,26 @@ func (c *Channel) Get(nick string) *User {
 		_log.Debug().
 			Str("nick", nick).
 			Msg("Creating new, empty user")
-		u = &User{Nick: nick}
 		c.Lock()
-		c.Users[nick] = u
-		c.Unlock()
+		defer c.Unlock()
+		if u, found = c.Users[nick]; !found { // unless added while unlocked
+			u = &User{Nick: nick}
+			c.Users[nick] = u
+		}
 	}
 	return u
 }
 
+// del removes the entry for nick
+func (c *Channel) del(nick string) {
+	c.Lock()
+	delete(c.Users, nick)
+	c.Unlock()
+}
+
 func (c *Channel) Nicks() []string {
+	c.RLock()
+	defer c.RUnlock()
 	nicks := make([]string, 0, len(c.Users))
 	for k := range c.Users {
 		nicks = append(nicks, k)
@@ -232,197 +376,382 @@ func (c *Channel) Nicks() []string {
 	return nicks
 }
 
-func GetMsg(msg, nick string) string {
-	if strings.Contains(msg, "%s") && nick != "" {
-		return fmt.Sprintf(msg, nick)
+// Find returns the entry that best matches id, or nil if none does. See Matcher for the order
+// of precedence when several match.
+func (c *Channel) Find(id Identity) *User {
+	c.RLock()
+	defer c.RUnlock()
+	var (
+		best *User
+		bm   Matcher
+	)
+	for key, u := range c.Users {
+		m, err := ParseMatcher(key)
+		if err != nil {
+			_log.Warn().
+				Err(err).
+				Str("matcher", key).
+				Msg("Skipping invalid matcher")
+			continue
+		}
+		if m.Match(id) && (best == nil || m.before(bm)) {
+			best, bm = u, m
+		}
 	}
-	return msg
+	return best
 }
 
-func (u *User) GetJMsg() string {
-	return GetMsg(u.JMsg, u.Nick)
+// lookup returns the key for the entry with the same matcher as spec, so e.g. "MyNick"
+// finds an entry stored as "mynick", or spec itself if not found
+func (c *Channel) lookup(spec string) string {
+	m, err := ParseMatcher(spec)
+	if err != nil {
+		return spec
+	}
+	c.RLock()
+	defer c.RUnlock()
+	for key := range c.Users {
+		if km, err := ParseMatcher(key); err == nil && km == m {
+			return key
+		}
+	}
+	return m.String()
 }
 
-func (u *User) GetQMsg() string {
-	return GetMsg(u.QMsg, u.Nick)
+func GetMsg(msg, nick string) string {
+	if strings.Contains(msg, "%s") && nick != "" {
+		return fmt.Sprintf(msg, nick)
+	}
+	return msg
 }
 
-func (u *User) SetJMsg(msg string) {
-	u.Lock()
-	u.JMsg = msg
-	u.Unlock()
+func isMe(nick string) bool {
+	return strings.EqualFold(nick, _conn.GetNick())
 }
 
-func (u *User) SetQMsg(msg string) {
-	u.Lock()
-	u.QMsg = msg
-	u.Unlock()
+// watched records that id was seen joining or leaving channel, for the entry that best
+// matches, and returns it with the data for its message. It returns nil if none matches,
+// or the plugin is not active in channel. The caller saves.
+func watched(channel string, id Identity, msgtype, reason string) (*User, MsgData) {
+	if !_plugin.Active(channel) {
+		return nil, MsgData{}
+	}
+	c := watchData().Get(channel)
+	u := c.Find(id)
+	if u == nil {
+		return nil, MsgData{}
+	}
+	d := MsgData{Nick: id.Nick, Channel: channel, Reason: reason}
+	// called before this is recorded, so it's what the nick did before
+	if s := c.LastSeen(id.Nick); s != nil {
+		d.LastSeen = s.Time
+		d.Since = humanDuration(time.Since(s.Time))
+	}
+	changed() // keep the rotation and count
+	return u, u.seen(msgtype, d)
 }
 
-func onJOIN(e *ircevent.Event) {
-	if e.Nick == _conn.GetNick() {
-		_log.Debug().
-			Str("nick", e.Nick).
-			Msg("Seems it's myself joining")
-		return
+// watchMsg returns the next message for msgtype for the entry that best matches id, or
+// empty if none is set, the plugin is not active in channel, or it's over the limits
+func watchMsg(channel string, id Identity, msgtype, reason string) string {
+	u, d := watched(channel, id, msgtype, reason)
+	if u == nil || !u.hasMsgs(msgtype) {
+		return ""
 	}
-
-	c := _wd.Get(e.Arguments[0])
-	if len(c.Users) == 0 {
-		return
+	if !_limiter.allow(channel, id.Nick, msgtype, time.Now()) {
+		_log.Debug().
+			Str("channel", channel).
+			Str("nick", id.Nick).
+			Str("type", msgtype).
+			Msg("Over the limit, not sending")
+		return ""
 	}
+	return u.nextMsg(msgtype, d)
+}
 
-	if !c.Has(e.Nick) {
+// welcomeBack welcomes the nicks that returned from a netsplit to channel, in one message
+func welcomeBack(channel string, nicks []string) {
+	if !_limiter.allow(channel, "", cmdJoin, time.Now()) {
 		return
 	}
+	send(channel, "Welcome back from the netsplit, "+strings.Join(nicks, ", "), nil)
+}
 
-	msg := c.Get(e.Nick).GetJMsg()
+// send sends msg to channel, unless empty. e is the event it's a reply to, if any.
+func send(channel, msg string, e *ircevent.Event) {
 	if msg == "" {
 		return
 	}
-
+	var sender *bot.User
+	if e != nil {
+		sender = &bot.User{
+			ID:       e.Host,
+			Nick:     e.Nick,
+			RealName: e.User,
+		}
+	}
 	_bot.SendMessage(
 		bot.OutgoingMessage{
-			Target:  e.Arguments[0], // will be the channel name
+			Target:  channel,
 			Message: msg,
-			Sender: &bot.User{
-				ID:       e.Host,
-				Nick:     e.Nick,
-				RealName: e.User,
-			},
+			Sender:  sender,
 		},
 	)
 }
 
-func onQUIT(e *ircevent.Event) {
-	if e.Nick == _conn.GetNick() {
+// onWelcome forgets all members when (re)connecting, as we get new NAMES lists when joining.
+// It also asks for the capabilities that tell us the services account of users, which
+// ircevent doesn't let us ask for when connecting. Servers without them just say no.
+func onWelcome(*ircevent.Event) {
+	_members.reset()
+	_splits.reset()
+	sendRaw("CAP REQ :" + strings.Join(wantedCaps, " "))
+}
+
+// onNAMES adds the nicks from a NAMES reply, which has the channel as the third argument,
+// and the nicks as the last
+func onNAMES(e *ircevent.Event) {
+	if len(e.Arguments) < 4 {
+		return
+	}
+	_members.add(e.Arguments[2], strings.Fields(e.Arguments[3])...)
+}
+
+func onJOIN(e *ircevent.Event) {
+	channel := e.Arguments[0]
+	if isMe(e.Nick) {
 		_log.Debug().
 			Str("nick", e.Nick).
-			Msg("Seems it's myself leaving")
+			Msg("Seems it's myself joining")
+		_members.leave(channel) // the NAMES reply that follows has everyone
 		return
 	}
+	_members.add(channel, e.Nick)
+	id := identityOf(e)
+	if _splits.rejoined(channel, e.Nick, time.Now()) {
+		// welcomed back with everyone else returning from the split
+		if u, _ := watched(channel, id, cmdJoin, ""); u != nil && u.hasMsgs(cmdJoin) {
+			_splits.welcome(channel, e.Nick, welcomeBack)
+		}
+	} else {
+		send(channel, watchMsg(channel, id, cmdJoin, ""), e)
+	}
+	record(channel, e.Nick, cmdJoin, "")
+	deliver(channel, e.Nick, e)
+	save(true)
+}
 
-	c := _wd.Get(_cfg.Channels[0])
-	if len(c.Users) == 0 {
+func onPART(e *ircevent.Event) {
+	channel := e.Arguments[0]
+	if isMe(e.Nick) {
+		_members.leave(channel)
 		return
 	}
+	_members.remove(channel, e.Nick)
+	var reason string
+	if len(e.Arguments) > 1 {
+		reason = e.Arguments[1]
+	}
+	send(channel, watchMsg(channel, identityOf(e), cmdPart, reason), e)
+	record(channel, e.Nick, cmdPart, reason)
+	save(true)
+}
 
-	if !c.Has(e.Nick) {
+func onKICK(e *ircevent.Event) {
+	if len(e.Arguments) < 2 {
 		return
 	}
-
-	msg := c.Get(e.Nick).GetQMsg()
-	if msg == "" {
+	channel, nick := e.Arguments[0], e.Arguments[1]
+	if isMe(nick) {
+		_members.leave(channel)
 		return
 	}
+	_members.remove(channel, nick)
+	var reason string
+	if len(e.Arguments) > 2 {
+		reason = e.Arguments[2]
+	}
+	record(channel, nick, cmdKick, reason)
+	save(true)
+}
 
-	// maybe we should loop through all channels and send a msg for each here
-	_bot.SendMessage(
-		bot.OutgoingMessage{
-			Target:  _cfg.Channels[0], // on QUIT e.Arguments[0] is empty, so we use this instead, even if not pretty
-			Message: msg,
-			Sender: &bot.User{
-				ID:       e.Host,
-				Nick:     e.Nick,
-				RealName: e.User,
-			},
-		},
-	)
+// onNICK records the change for both nicks, in every channel shared with the bot, and
+// delivers the messages waiting for the new nick there
+func onNICK(e *ircevent.Event) {
+	newNick := e.Message()
+	for _, channel := range _members.rename(e.Nick, newNick) {
+		record(channel, e.Nick, cmdNick, newNick)
+		record(channel, newNick, actNickFrom, e.Nick)
+		deliver(channel, newNick, e)
+	}
+	save(true)
+}
+
+// onQUIT sends the quit message for the nick to every channel it shared with the bot, as the
+// QUIT itself has no channel. In a netsplit, there's no message, as they'll likely be back soon.
+func onQUIT(e *ircevent.Event) {
+	if isMe(e.Nick) {
+		_log.Debug().
+			Str("nick", e.Nick).
+			Msg("Seems it's myself leaving")
+		return
+	}
+	id := identityOf(e)
+	split := isNetsplit(e.Message())
+	for _, channel := range _members.quit(e.Nick) {
+		if split {
+			if _plugin.Active(channel) {
+				_splits.quit(channel, e.Nick, time.Now())
+			}
+		} else {
+			send(channel, watchMsg(channel, id, cmdQuit, e.Message()), e)
+		}
+		record(channel, e.Nick, cmdQuit, e.Message())
+	}
+	save(true)
 }
 
 func mtype(in, compare string) bool {
 	return strings.ToUpper(in) == compare
 }
 
-func ls(channel, nick, msgtype string) string {
-	c := _wd.Get(channel)
-	if len(c.Users) == 0 {
-		return fmt.Sprintf("%s: No configured messages for channel %q", plugin, channel)
+func ls(channel, spec, msgtype string) string {
+	c := watchData().Get(channel)
+	nicks := c.Nicks()
+	if len(nicks) == 0 {
+		return fmt.Sprintf("%s: No configured messages for channel %q", title, channel)
+	}
+	if spec == "" {
+		return fmt.Sprintf("%s matchers: %s", title, strings.Join(nicks, ", "))
 	}
-	if nick == "" {
-		return fmt.Sprintf("%s nicks: %s", plugin, strings.Join(c.Nicks(), ", "))
+	key := c.lookup(spec)
+	if !c.Has(key) {
+		return fmt.Sprintf("%s: No configured messages for %q", title, spec)
 	}
-	if !c.Has(nick) {
-		return fmt.Sprintf("%s: No configured messages for nick %q", plugin, nick)
+	u := c.Get(key)
+	str := fmt.Sprintf("%s: messages for %s:\n", title, key)
+	list := func(msgtype string) {
+		for idx, msg := range u.Msgs(msgtype) {
+			str += fmt.Sprintf("  %s #%d: %s\n", msgtype, idx+1, msg)
+		}
 	}
-	u := c.Get(nick)
-	str := fmt.Sprintf("%s: messages for %s:\n", plugin, nick)
 	if mtype(msgtype, cmdJoin) {
-		str += fmt.Sprintf("  %s: %s\n", cmdJoin, u.JMsg)
+		list(cmdJoin)
 	} else if mtype(msgtype, cmdQuit) || mtype(msgtype, cmdPart) {
-		str += fmt.Sprintf("  %s: %s\n", cmdQuit, u.QMsg)
+		list(cmdQuit)
 	} else {
-		str += fmt.Sprintf("  %s: %s\n  %s: %s\n", cmdJoin, u.JMsg, cmdQuit, u.QMsg)
+		list(cmdJoin)
+		list(cmdQuit)
 	}
 	return str
 }
 
-func reload() {
-	_wd = NewWatchData().LoadFile(_cfgfile) // will return new instance on error
+// Reload reads the config file again, keeping the current config if it fails
+func Reload() error {
+	wd, err := NewWatchData().loadFile(_cfgfile)
+	if err != nil {
+		return err
+	}
+	setWatchData(wd)
+	return nil
 }
 
 func clear() error {
-	_wd = NewWatchData()
-	return _wd.SaveFile(_cfgfile)
+	wd := NewWatchData()
+	setWatchData(wd)
+	return wd.SaveFile(_cfgfile)
 }
 
-func add(channel, nick, msgtype, msg string) (string, error) {
-	if nick == "" || msgtype == "" || msg == "" {
+func add(channel, spec, msgtype, msg string) (string, error) {
+	if spec == "" || msgtype == "" || msg == "" {
 		_log.Error().
 			Str("func", "add()").
-			Msg("Empty nick, msgtype or msg")
-		emsg := cmdAdd + " Error: nick, message type and message has to be set"
+			Msg("Empty matcher, msgtype or msg")
+		emsg := cmdAdd + " Error: matcher, message type and message has to be set"
+		return emsg, fmt.Errorf(emsg)
+	}
+	if _, err := ParseMatcher(spec); err != nil {
+		return fmt.Sprintf("%s Error: %s", cmdAdd, err), err
+	}
+	if mtype(msgtype, cmdPart) {
+		msgtype = cmdQuit
+	}
+	if !mtype(msgtype, cmdJoin) && !mtype(msgtype, cmdQuit) {
+		emsg := fmt.Sprintf("%s Error: message type must be %s, %s or %s", cmdAdd, cmdJoin, cmdPart, cmdQuit)
 		return emsg, fmt.Errorf(emsg)
 	}
+	if _, err := parseMsg(msg); err != nil {
+		return fmt.Sprintf("%s Error: invalid template: %s", cmdAdd, err), err
+	}
 
-	c := _wd.Get(channel)
-	u := c.Get(nick)
+	c := watchData().Get(channel)
+	key := c.lookup(spec)
+	u := c.Get(key)
 
-	ret := fmt.Sprintf("%s: Set/updated %s message for nick %q", plugin, "%s", nick)
-	if mtype(msgtype, cmdJoin) {
-		u.SetJMsg(msg)
-		ret = fmt.Sprintf(ret, cmdJoin)
-	} else if mtype(msgtype, cmdQuit) || mtype(msgtype, cmdPart) {
-		u.SetQMsg(msg)
-		ret = fmt.Sprintf(ret, cmdQuit)
+	msgtype = strings.ToUpper(msgtype)
+	if !u.AddMsg(msgtype, msg) {
+		return fmt.Sprintf("%s: %s message already set for %q", title, msgtype, key), nil
 	}
+	ret := fmt.Sprintf("%s: Added %s message #%d for %q", title, msgtype, len(u.Msgs(msgtype)), key)
 
-	return ret, _wd.SaveFile(_cfgfile)
+	return ret, watchData().SaveFile(_cfgfile)
 }
 
-func del(channel, nick, msgtype string) (string, error) {
-	if nick == "" {
-		emsg := "empty nick"
+func del(channel, spec, msgtype, num string) (string, error) {
+	if spec == "" {
+		emsg := "empty matcher"
 		_log.Error().
 			Str("func", "del()").
 			Msg(emsg)
 		return emsg, fmt.Errorf(emsg)
 	}
 
-	c := _wd.Get(channel)
-	u := c.Get(nick)
+	c := watchData().Get(channel)
+	key := c.lookup(spec)
+	u := c.Get(key)
 
 	cleanup := func() {
-		if u.JMsg == "" && u.QMsg == "" {
-			delete(c.Users, nick)
+		if u.empty() {
+			c.del(key)
 		}
 	}
+	// deletes message number num, or all if num is not given
+	delMsgs := func(msgtype string) (string, error) {
+		if num == "" {
+			u.setMsg(msgtype, "")
+			cleanup()
+			return fmt.Sprintf("%s: Deleted %s messages for %q", title, msgtype, key), nil
+		}
+		n, err := strconv.Atoi(num)
+		if err == nil {
+			err = u.DelMsg(msgtype, n)
+		}
+		if err != nil {
+			return fmt.Sprintf("%s: %s", title, err), err
+		}
+		cleanup()
+		return fmt.Sprintf("%s: Deleted %s message #%d for %q", title, msgtype, n, key), nil
+	}
 
-	ret := fmt.Sprintf("%s: Deleted %s message for nick %q", plugin, "%s", nick)
+	var ret string
 	if mtype(msgtype, cmdJoin) {
-		u.SetJMsg("")
-		ret = fmt.Sprintf(ret, cmdJoin)
-		cleanup()
+		msg, err := delMsgs(cmdJoin)
+		if err != nil {
+			return msg, err
+		}
+		ret = msg
 	} else if mtype(msgtype, cmdQuit) || mtype(msgtype, cmdPart) {
-		u.SetQMsg("")
-		ret = fmt.Sprintf(ret, cmdQuit)
-		cleanup()
+		msg, err := delMsgs(cmdQuit)
+		if err != nil {
+			return msg, err
+		}
+		ret = msg
 	} else if msgtype == "" {
-		delete(c.Users, nick)
-		ret = fmt.Sprintf("%s: Deleted nick %q", plugin, nick)
+		c.del(key)
+		ret = fmt.Sprintf("%s: Deleted %q", title, key)
 	}
 
-	return ret, _wd.SaveFile(_cfgfile)
+	return ret, watchData().SaveFile(_cfgfile)
 }
 
 func safeArgs(num int, args []string) []string {
@@ -441,16 +770,18 @@ func safeArgs(num int, args []string) []string {
 // Handle runtime commands here
 func userwatch(cmd *bot.Cmd) (string, error) {
 	// Arguments:
-	//	add <nick> <join|part|quit> <msg>
-	//	del <nick> [join|part|quit]
-	//	ls  [nick] [join|part|quit]
+	//	add <matcher> <join|part|quit> <msg>
+	//	del <matcher> [join|part|quit] [num]
+	//	ls  [matcher] [join|part|quit]
 	//	reload
 	//	clear ("secret")
 	//
 	// quit and part are synonymous.
-	// del <nick> with no more args deletes the nick altogether from the map.
-	// ls <nick> with no more args shows both messages for join/quit.
-	// ls with no more args shows a list of nicks that have messages set.
+	// add adds a message to the ones rotated for the type.
+	// del <matcher> with no more args deletes the matcher altogether from the map.
+	// del <matcher> <type> deletes all messages of the type, or just number num, as listed by ls.
+	// ls <matcher> with no more args shows all messages for join/quit.
+	// ls with no more args shows a list of matchers that have messages set.
 	// clear deletes everything without confirmation
 
 	alen := len(cmd.Args)
@@ -458,24 +789,29 @@ func userwatch(cmd *bot.Cmd) (string, error) {
 		return "", nil
 	}
 
-	args := safeArgs(3, cmd.Args) // 3 is the longest possible set of args
+	args := safeArgs(4, cmd.Args) // 4 is the longest possible set of args, not counting the message for add
 	var retmsg string
 
 	if mtype(args[0], cmdList) {
 		return ls(cmd.Channel, args[1], args[2]), nil
 	} else if mtype(args[0], cmdAdd) {
-		msg := strings.Join(cmd.Args[3:len(cmd.Args)], " ")
+		var msg string
+		if alen > 3 {
+			msg = strings.Join(cmd.Args[3:], " ")
+		}
 		return add(cmd.Channel, args[1], args[2], msg)
 	} else if mtype(args[0], cmdDel) {
-		return del(cmd.Channel, args[1], args[2])
+		return del(cmd.Channel, args[1], args[2], args[3])
 	} else if mtype(args[0], cmdClear) {
 		if err := clear(); err != nil {
 			return "", err
 		}
-		retmsg = fmt.Sprintf("%s: DB cleared", plugin)
+		retmsg = fmt.Sprintf("%s: DB cleared", title)
 	} else if mtype(args[0], cmdReload) {
-		reload()
-		retmsg = fmt.Sprintf("%s: DB reloaded from disk", plugin)
+		if err := Reload(); err != nil {
+			return fmt.Sprintf("%s: Reload failed, keeping current DB: %s", title, err), err
+		}
+		retmsg = fmt.Sprintf("%s: DB reloaded from disk", title)
 	}
 
 	return retmsg, nil
@@ -0,0 +1,156 @@
+package userwatch
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chat-bot/bot"
+	ircevent "github.com/thoj/go-ircevent"
+
+	"github.com/oddlid/dvdgbot/plugin"
+)
+
+const (
+	testNick    = "leetbot"
+	testChannel = "#blackhole"
+	otherChan   = "#other"
+	thirdChan   = "#third"
+)
+
+// setupTest sets up the plugin on a connection that's never connected, where the test feeds
+// it events, and returns what the bot sends as "target: message". Raw lines sent to the
+// server are returned as they are.
+func setupTest(t *testing.T, channels ...string) (*ircevent.Connection, chan string) {
+	t.Helper()
+
+	wd := NewWatchData()
+	for _, channel := range []string{testChannel, otherChan, thirdChan} {
+		u := wd.Get(channel).Get("alice")
+		u.SetJMsg("Welcome, %s")
+		u.SetQMsg("Bye, %s")
+	}
+	cfgfile := filepath.Join(t.TempDir(), "userwatch.json")
+	if err := wd.SaveFile(cfgfile); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan string, 16)
+	sendRaw = func(line string) {
+		out <- line
+	}
+	b := bot.New(
+		&bot.Handlers{
+			Response: func(target, message string, _ *bot.User) {
+				out <- target + ": " + message
+			},
+		},
+		&bot.Config{},
+	)
+	ic := ircevent.IRC(testNick, testNick)
+	host := plugin.Host{
+		Bot:      b,
+		Conn:     ic,
+		Settings: Settings{ConfigFile: cfgfile},
+	}
+	if err := plugin.Setup(PluginName, host, channels); err != nil {
+		t.Fatal(err)
+	}
+	return ic, out
+}
+
+func event(ic *ircevent.Connection, code, nick string, args ...string) {
+	ic.RunCallbacks(&ircevent.Event{Code: code, Nick: nick, Arguments: args})
+}
+
+// join makes the bot join channel, with the given nicks already there
+func join(ic *ircevent.Connection, channel string, nicks ...string) {
+	event(ic, cmdJoin, testNick, channel)
+	event(ic, rplNamReply, "server", testNick, "=", channel, "@"+testNick+" "+strings.Join(nicks, " "))
+}
+
+// expect checks that exactly the given messages are sent, in order
+func expect(t *testing.T, out chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-out:
+			if got != w {
+				t.Errorf("Expected %q, got %q", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", w)
+		}
+	}
+	select {
+	case got := <-out:
+		t.Errorf("Unexpected message: %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQuitSharedChannels(t *testing.T) {
+	ic, out := setupTest(t)
+	event(ic, rplWelcome, "server", testNick, "Welcome")
+	expect(t, out, "CAP REQ :account-tag extended-join")
+	join(ic, testChannel, "+alice", "bob")
+	join(ic, otherChan, "@Alice")
+	join(ic, thirdChan, "bob")
+
+	// alice is in two of the channels, and the quit message goes to both
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out, testChannel+": Bye, alice", otherChan+": Bye, alice")
+
+	// and she's gone from both after quitting
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out)
+
+	event(ic, cmdJoin, "alice", thirdChan)
+	expect(t, out, thirdChan+": Welcome, alice")
+	event(ic, cmdPart, "alice", thirdChan)
+	expect(t, out, thirdChan+": Bye, alice")
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out)
+
+	// nobody has messages for bob
+	event(ic, cmdQuit, "bob", "Quit: bye")
+	expect(t, out)
+}
+
+func TestMembershipChanges(t *testing.T) {
+	ic, out := setupTest(t)
+	join(ic, testChannel, "carol")
+	join(ic, otherChan, "alice")
+
+	// carol becomes alice in testChannel, while alice is kicked from otherChan
+	event(ic, cmdNick, "carol", "alice")
+	event(ic, cmdKick, "op", otherChan, "alice", "behave")
+	expect(t, out)
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out, testChannel+": Bye, alice")
+
+	// when the bot parts a channel, it forgets who's there
+	event(ic, cmdJoin, "alice", testChannel)
+	expect(t, out, testChannel+": Welcome, alice")
+	event(ic, cmdPart, testNick, testChannel)
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out)
+
+	// and so it does when reconnecting
+	join(ic, otherChan, "alice")
+	event(ic, rplWelcome, "server", testNick, "Welcome")
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out, "CAP REQ :account-tag extended-join")
+}
+
+func TestScoped(t *testing.T) {
+	ic, out := setupTest(t, otherChan)
+	join(ic, testChannel, "alice")
+	join(ic, otherChan, "alice")
+
+	event(ic, cmdJoin, "alice", thirdChan)
+	expect(t, out)
+	event(ic, cmdQuit, "alice", "Quit: bye")
+	expect(t, out, otherChan+": Bye, alice")
+}
@@ -9,14 +9,38 @@ import (
 
 	"github.com/go-chat-bot/bot"
 	"github.com/nishanths/go-xkcd/v2"
+
+	"github.com/oddlid/dvdgbot/plugin"
 )
 
 const (
 	DefaultCommandName = `xkcd`
 	Description        = `Fetch an XKCD comic image`
 	Params             = `get <ID>|random|latest`
+	DefaultTimeout     = 3 * time.Second
 )
 
+// Settings for the plugin, as given in the bot's config file
+type Settings struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func init() {
+	plugin.Register(DefaultCommandName, setupPlugin)
+}
+
+func setupPlugin(p *plugin.Plugin, h plugin.Host) error {
+	s, _ := h.Settings.(Settings)
+	if s.Timeout <= 0 {
+		s.Timeout = DefaultTimeout
+	}
+	b := New(s.Timeout, func() context.Context {
+		return h.Ctx
+	})
+	p.RegisterCommand(DefaultCommandName, Description, Params, b.Fetch)
+	return nil
+}
+
 type ContextFunc func() context.Context
 
 type Bot struct {